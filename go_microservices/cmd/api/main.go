@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -10,16 +11,42 @@ import (
 	"syscall"
 	"time"
 
+	goredis "github.com/redis/go-redis/v9"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/cache"
 	"go_microservices/internal/config"
 	"go_microservices/internal/handler"
+	"go_microservices/internal/migrate"
+	"go_microservices/internal/models"
+	"go_microservices/internal/ratelimit"
+	"go_microservices/internal/repository/memory"
 	"go_microservices/internal/repository/postgres"
 	"go_microservices/internal/repository/redis"
 	"go_microservices/internal/service"
+	"go_microservices/migrations"
 	"go_microservices/pkg/database"
 )
 
 func main() {
 	cfg := config.Load()
+	if !models.ValidCurrency(cfg.BaseCurrency) {
+		log.Fatalf("Unsupported BASE_CURRENCY %q", cfg.BaseCurrency)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(cfg, os.Args[2:])
+			return
+		case "purge":
+			runPurge(cfg, os.Args[2:])
+			return
+		case "role":
+			runRole(cfg, os.Args[2:])
+			return
+		}
+	}
 
 	db, err := database.NewPostgres(cfg)
 	if err != nil {
@@ -27,33 +54,106 @@ func main() {
 	}
 	defer db.Close()
 
-	rdb, err := database.NewRedis(cfg)
+	if cfg.MigrateOnStart {
+		migrator, err := migrate.New(db, migrations.FS)
+		if err != nil {
+			log.Fatal("Failed to load migrations:", err)
+		}
+		if err := autoMigrate(context.Background(), migrator); err != nil {
+			log.Fatal("Failed to apply migrations:", err)
+		}
+	}
+
+	tokens, err := newTokenIssuer(cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to Redis:", err)
+		log.Fatal("Failed to configure authentication:", err)
+	}
+
+	var rdb *goredis.Client
+	if cfg.CacheDriver == cache.DriverRedis || cfg.RefreshTokenStore == "redis" ||
+		cfg.RateLimitDriver == ratelimit.DriverRedis {
+		rdb, err = database.NewRedis(cfg)
+		if err != nil {
+			log.Fatal("Failed to connect to Redis:", err)
+		}
+		defer rdb.Close()
+	}
+
+	cacheRepo, closeCache, err := newCache(cfg, rdb)
+	if err != nil {
+		log.Fatal("Failed to initialize cache:", err)
+	}
+	defer closeCache()
+
+	refreshStore, err := newRefreshTokenStore(cfg, rdb)
+	if err != nil {
+		log.Fatal("Failed to initialize refresh token store:", err)
+	}
+
+	rateLimitRules, err := ratelimit.ParseRules(cfg.RateLimitRules)
+	if err != nil {
+		log.Fatal("Failed to parse RATE_LIMIT_RULES:", err)
+	}
+	limiter, err := newRateLimiter(cfg, rdb)
+	if err != nil {
+		log.Fatal("Failed to initialize rate limiter:", err)
 	}
-	defer rdb.Close()
 
 	userRepo := postgres.NewUserRepository(db)
 	productRepo := postgres.NewProductRepository(db)
-	cacheRepo := redis.NewCacheRepository(rdb, cfg.CacheTTL)
+	categoryRepo := postgres.NewCategoryRepository(db)
+	orderRepo := postgres.NewOrderRepository(db)
+	reservationRepo := postgres.NewReservationRepository(db)
+	movementRepo := postgres.NewStockMovementRepository(db)
+	apiKeyRepo := postgres.NewAPIKeyRepository(db)
 
 	userService := service.NewUserService(userRepo, cacheRepo)
-	productService := service.NewProductService(productRepo, cacheRepo)
+	authService := service.NewAuthService(userRepo, tokens, refreshStore, cacheRepo, cfg.RefreshTokenTTL)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, cacheRepo)
+	productService := service.NewProductService(productRepo, movementRepo, cacheRepo, cfg.BaseCurrency)
+	categoryService := service.NewCategoryService(categoryRepo, cacheRepo)
+	orderService := service.NewOrderService(orderRepo, productRepo, userRepo, movementRepo, cacheRepo)
+	reservationService := service.NewReservationService(
+		reservationRepo, productRepo, movementRepo, cacheRepo, cfg.ReservationTTL,
+	)
+
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	reservationService.StartReaper(reaperCtx, cfg.ReservationReapInterval)
 
+	authHandler := handler.NewAuthHandler(authService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
 	userHandler := handler.NewUserHandler(userService)
 	productHandler := handler.NewProductHandler(productService)
+	categoryHandler := handler.NewCategoryHandler(categoryService, productService)
+	orderHandler := handler.NewOrderHandler(orderService)
+	reservationHandler := handler.NewReservationHandler(reservationService)
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", handleHome)
-	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/health", handleHealth(cacheRepo))
 
+	authHandler.RegisterRoutes(mux)
+	apiKeyHandler.RegisterRoutes(mux)
 	userHandler.RegisterRoutes(mux)
 	productHandler.RegisterRoutes(mux)
+	categoryHandler.RegisterRoutes(mux)
+	orderHandler.RegisterRoutes(mux)
+	reservationHandler.RegisterRoutes(mux)
+
+	var root http.Handler = mux
+	if limiter != nil {
+		root = handler.RateLimit(limiter, rateLimitRules, cfg.RateLimitTrustedProxies, root)
+	}
+	root = handler.Authenticate(tokens, apiKeyService, root)
+	if limiter != nil {
+		root = handler.LimitAuthFailures(limiter, rateLimitRules, cfg.RateLimitTrustedProxies, root)
+	}
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      mux,
+		Handler:      root,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -63,7 +163,12 @@ func main() {
 		log.Printf("Server starting on port %s", cfg.Port)
 		log.Printf("Environment: %s", cfg.AppEnv)
 		log.Printf("PostgreSQL: %s:%s", cfg.DBHost, cfg.DBPort)
-		log.Printf("Redis: %s:%s", cfg.RedisHost, cfg.RedisPort)
+		if cfg.CacheDriver == cache.DriverRedis {
+			log.Printf("Cache: redis %s:%s (L1: %t)", cfg.RedisHost, cfg.RedisPort, cfg.CacheL1Enabled)
+		} else {
+			log.Printf("Cache: %s", cfg.CacheDriver)
+		}
+		log.Printf("Rate limiting: %s", cfg.RateLimitDriver)
 
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
@@ -73,6 +178,88 @@ func main() {
 	gracefulShutdown(srv)
 }
 
+// newTokenIssuer signs access tokens with JWT_SECRET. Outside development the
+// secret is required: a generated one would log everybody out on restart and
+// differ between replicas.
+func newTokenIssuer(cfg *config.Config) (*auth.TokenIssuer, error) {
+	secret := cfg.JWTSecret
+	if secret == "" {
+		if cfg.AppEnv != "development" {
+			return nil, fmt.Errorf("JWT_SECRET is not set")
+		}
+		generated, err := auth.NewSecret()
+		if err != nil {
+			return nil, err
+		}
+		log.Println("JWT_SECRET is not set, using a random secret; tokens will not survive a restart")
+		secret = generated
+	}
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("JWT_SECRET must be at least 32 bytes long")
+	}
+	return auth.NewTokenIssuer([]byte(secret), cfg.AccessTokenTTL), nil
+}
+
+// newRefreshTokenStore builds the store selected by REFRESH_TOKEN_STORE. The
+// memory store only suits a single replica.
+func newRefreshTokenStore(cfg *config.Config, rdb *goredis.Client) (service.RefreshTokenStore, error) {
+	switch cfg.RefreshTokenStore {
+	case "redis":
+		return redis.NewRefreshTokenStore(rdb), nil
+	case "memory":
+		return memory.NewRefreshTokenStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown REFRESH_TOKEN_STORE %q", cfg.RefreshTokenStore)
+	}
+}
+
+// newRateLimiter builds the limiter selected by RATE_LIMIT_DRIVER, or nil if
+// rate limiting is disabled. Only the redis limiter holds across replicas.
+func newRateLimiter(cfg *config.Config, rdb *goredis.Client) (ratelimit.Limiter, error) {
+	switch cfg.RateLimitDriver {
+	case ratelimit.DriverRedis:
+		return redis.NewRateLimiter(rdb), nil
+	case ratelimit.DriverMemory:
+		return ratelimit.NewMemoryLimiter(), nil
+	case ratelimit.DriverNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown RATE_LIMIT_DRIVER %q", cfg.RateLimitDriver)
+	}
+}
+
+// newCache builds the cache selected by CACHE_DRIVER and returns a function
+// that releases its resources. rdb is only used by the redis driver.
+func newCache(cfg *config.Config, rdb *goredis.Client) (cache.Cache, func(), error) {
+	opts := cache.Options{
+		TTL:         cfg.CacheTTL,
+		StaleTTL:    cfg.CacheStaleTTL,
+		NegativeTTL: cfg.CacheNegativeTTL,
+	}
+
+	switch cfg.CacheDriver {
+	case cache.DriverRedis:
+		l2 := redis.NewCacheRepository(rdb, opts)
+		if !cfg.CacheL1Enabled {
+			return l2, func() {}, nil
+		}
+
+		l1 := cache.NewMemoryCache(cfg.CacheMaxEntries, cache.Options{
+			TTL:         cfg.CacheL1TTL,
+			NegativeTTL: min(cfg.CacheL1TTL, cfg.CacheNegativeTTL),
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		bus := redis.NewInvalidationBus(rdb, cfg.CacheInvalidationChannel)
+		return cache.NewTieredCache(ctx, l1, l2, bus), cancel, nil
+	case cache.DriverMemory:
+		return cache.NewMemoryCache(cfg.CacheMaxEntries, opts), func() {}, nil
+	case cache.DriverNone:
+		return cache.NewNoopCache(), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown CACHE_DRIVER %q", cfg.CacheDriver)
+	}
+}
+
 func handleHome(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
@@ -84,17 +271,51 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 		"version": "1.0.0",
 		"endpoints": []string{
 			"GET    /health",
+			"POST   /auth/register",
+			"POST   /auth/login",
+			"POST   /auth/refresh",
+			"POST   /auth/logout",
+			"POST   /admin/api-keys",
+			"GET    /admin/api-keys",
+			"DELETE /admin/api-keys/{id}",
 			"GET    /users",
+			"GET    /users/export",
 			"POST   /users",
 			"GET    /users/{id}",
 			"PUT    /users/{id}",
+			"PATCH  /users/{id}",
 			"DELETE /users/{id}",
+			"POST   /users/{id}/restore",
+			"PUT    /users/{id}/role",
 			"GET    /products",
+			"GET    /products/export",
 			"POST   /products",
+			"POST   /products/import",
 			"GET    /products/{id}",
 			"PUT    /products/{id}",
+			"PATCH  /products/{id}",
 			"DELETE /products/{id}",
+			"POST   /products/{id}/restore",
+			"PUT    /products/{id}/prices",
+			"DELETE /products/{id}/prices/{currency}",
 			"PATCH  /products/{id}/stock",
+			"POST   /products/{id}/restock",
+			"GET    /products/{id}/stock/history",
+			"GET    /products/{id}/categories",
+			"PUT    /products/{id}/categories",
+			"GET    /categories",
+			"POST   /categories",
+			"GET    /categories/{id}",
+			"PUT    /categories/{id}",
+			"DELETE /categories/{id}",
+			"GET    /categories/{id}/products",
+			"POST   /products/{id}/reservations",
+			"GET    /reservations/{id}",
+			"POST   /reservations/{id}/commit",
+			"POST   /reservations/{id}/release",
+			"POST   /orders",
+			"GET    /orders/{id}",
+			"GET    /users/{id}/orders",
 		},
 	}
 
@@ -102,14 +323,19 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	response := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
-	}
+func handleHealth(c cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"status":    "healthy",
+			"timestamp": time.Now().Format(time.RFC3339),
+		}
+		if reporter, ok := c.(cache.StatsReporter); ok {
+			response["cache"] = reporter.Stats()
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
 }
 
 func gracefulShutdown(srv *http.Server) {
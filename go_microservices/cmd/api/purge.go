@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/config"
+	"go_microservices/internal/repository/postgres"
+	"go_microservices/internal/service"
+	"go_microservices/pkg/database"
+)
+
+const purgeUsage = "usage: api purge [retention, e.g. 720h]"
+
+// runPurge implements the "purge" subcommand: it hard-deletes users and
+// products that have been soft-deleted for longer than the retention period.
+func runPurge(cfg *config.Config, args []string) {
+	retention := cfg.SoftDeleteRetention
+	if len(args) > 1 {
+		log.Fatal(purgeUsage)
+	}
+	if len(args) == 1 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d < 0 {
+			log.Fatal(purgeUsage)
+		}
+		retention = d
+	}
+
+	db, err := database.NewPostgres(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to PostgreSQL:", err)
+	}
+	defer db.Close()
+
+	var rdb *goredis.Client
+	if cfg.CacheDriver == cache.DriverRedis {
+		rdb, err = database.NewRedis(cfg)
+		if err != nil {
+			log.Fatal("Failed to connect to Redis:", err)
+		}
+		defer rdb.Close()
+	}
+
+	cacheRepo, closeCache, err := newCache(cfg, rdb)
+	if err != nil {
+		log.Fatal("Failed to initialize cache:", err)
+	}
+	defer closeCache()
+
+	userService := service.NewUserService(postgres.NewUserRepository(db), cacheRepo)
+	productService := service.NewProductService(
+		postgres.NewProductRepository(db), postgres.NewStockMovementRepository(db), cacheRepo, cfg.BaseCurrency,
+	)
+
+	ctx := context.Background()
+	before := time.Now().Add(-retention)
+
+	users, keptUsers, err := userService.Purge(ctx, before)
+	if err != nil {
+		log.Fatalf("Failed to purge users: %v", err)
+	}
+	products, keptProducts, err := productService.Purge(ctx, before)
+	if err != nil {
+		log.Fatalf("Failed to purge products: %v", err)
+	}
+
+	fmt.Printf("Purged %d users and %d products deleted before %s\n",
+		users, products, before.Format(time.RFC3339))
+	if keptUsers > 0 || keptProducts > 0 {
+		fmt.Printf("Kept %d users with orders and %d products referenced by orders or stock movements\n",
+			keptUsers, keptProducts)
+	}
+}
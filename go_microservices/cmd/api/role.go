@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/config"
+	"go_microservices/internal/repository/postgres"
+	"go_microservices/internal/service"
+	"go_microservices/pkg/database"
+)
+
+const roleUsage = "usage: api role <email> admin | staff | customer"
+
+// runRole implements the "role" subcommand: it sets the role of the user with
+// the given email. It is how the first admin is made; after that admins can
+// use PUT /users/{id}/role.
+func runRole(cfg *config.Config, args []string) {
+	if len(args) != 2 {
+		log.Fatal(roleUsage)
+	}
+	email, role := args[0], args[1]
+
+	db, err := database.NewPostgres(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to PostgreSQL:", err)
+	}
+	defer db.Close()
+
+	var rdb *goredis.Client
+	if cfg.CacheDriver == cache.DriverRedis {
+		rdb, err = database.NewRedis(cfg)
+		if err != nil {
+			log.Fatal("Failed to connect to Redis:", err)
+		}
+		defer rdb.Close()
+	}
+
+	cacheRepo, closeCache, err := newCache(cfg, rdb)
+	if err != nil {
+		log.Fatal("Failed to initialize cache:", err)
+	}
+	defer closeCache()
+
+	userRepo := postgres.NewUserRepository(db)
+	userService := service.NewUserService(userRepo, cacheRepo)
+
+	user, err := userRepo.GetByEmail(email)
+	if err != nil {
+		log.Fatalf("Failed to find user: %v", err)
+	}
+	if user == nil || user.DeletedAt != nil {
+		log.Fatalf("No user with email %s", email)
+	}
+
+	user, err = userService.SetRole(context.Background(), user.ID, role, 0)
+	if err != nil {
+		log.Fatalf("Failed to set role: %v", err)
+	}
+
+	fmt.Printf("User %d (%s) is now %s\n", user.ID, user.Email, user.Role)
+}
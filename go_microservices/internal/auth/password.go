@@ -0,0 +1,133 @@
+// Package auth holds the credentials primitives: password hashes and signed
+// access tokens.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Passwords are hashed with Argon2id using the second recommended parameter
+// set of RFC 9106. Hashes carry their algorithm and parameters, so both can
+// change later: older hashes keep verifying, and NeedsRehash tells the caller
+// to replace them at the next login.
+const (
+	argon2Scheme  = "argon2id"
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Time    = 3
+	argon2Threads = 4
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+
+	// pbkdf2Scheme is the format passwords were hashed in before Argon2id:
+	// pbkdf2-sha256$<iterations>$<salt>$<key>.
+	pbkdf2Scheme = "pbkdf2-sha256"
+)
+
+// argon2Params are the cost parameters recorded in an Argon2id hash.
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+var currentArgon2Params = argon2Params{memory: argon2Memory, time: argon2Time, threads: argon2Threads}
+
+// HashPassword returns a hash of password in the PHC string format used by
+// the Argon2 reference implementation:
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := currentArgon2Params
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, argon2KeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2Scheme, argon2.Version,
+		p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// VerifyPassword reports whether password matches a hash made by
+// HashPassword, now or in an earlier format. A malformed or empty hash
+// matches nothing.
+func VerifyPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, pbkdf2Scheme+"$") {
+		return verifyPBKDF2(hash, password)
+	}
+
+	params, salt, want, ok := parseArgon2(hash)
+	if !ok {
+		return false
+	}
+	got := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// NeedsRehash reports whether hash was made with another algorithm or other
+// parameters than HashPassword uses now. Callers replace such a hash once the
+// password has been verified.
+func NeedsRehash(hash string) bool {
+	params, _, _, ok := parseArgon2(hash)
+	return !ok || params != currentArgon2Params
+}
+
+// parseArgon2 splits an Argon2id hash into its parameters, salt and key.
+func parseArgon2(hash string) (params argon2Params, salt, key []byte, ok bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != argon2Scheme {
+		return params, nil, nil, false
+	}
+	if parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return params, nil, nil, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, false
+	}
+	if params.memory == 0 || params.time == 0 || params.threads == 0 {
+		return params, nil, nil, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, false
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, false
+	}
+	return params, salt, key, true
+}
+
+// verifyPBKDF2 checks password against a PBKDF2-HMAC-SHA256 hash.
+func verifyPBKDF2(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(want) == 0 {
+		return false
+	}
+
+	got := pbkdf2.Key([]byte(password), salt, iterations, len(want), sha256.New)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
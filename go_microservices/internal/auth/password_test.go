@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Fatalf("hash %q does not record the algorithm and parameters", hash)
+	}
+	if !VerifyPassword(hash, "correct horse") {
+		t.Fatal("the password does not match its own hash")
+	}
+	if VerifyPassword(hash, "correct horse ") {
+		t.Fatal("a different password matches")
+	}
+	if NeedsRehash(hash) {
+		t.Fatal("a fresh hash needs a rehash")
+	}
+
+	again, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == hash {
+		t.Fatal("two hashes of one password share a salt")
+	}
+}
+
+func TestVerifyPasswordUsesStoredParameters(t *testing.T) {
+	// The Argon2id test vector of the reference implementation: "password"
+	// with salt "somesalt", t=2, m=64 MiB, p=1.
+	hash := "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	if !VerifyPassword(hash, "password") {
+		t.Fatal("a hash with other parameters does not verify")
+	}
+	if !NeedsRehash(hash) {
+		t.Fatal("a hash with other parameters does not need a rehash")
+	}
+}
+
+// The vectors are the published PBKDF2-HMAC-SHA256 test vectors: the RFC 6070
+// inputs with SHA-256 results, and the first vector of RFC 7914, section 11.
+var pbkdf2Vectors = []struct {
+	password, salt string
+	iterations     int
+	key            string
+}{
+	{"password", "salt", 1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+	{"password", "salt", 2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+	{"password", "salt", 4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	{
+		"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096,
+		"348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9",
+	},
+	{"pass\x00word", "sa\x00lt", 4096, "89b69d0516f829893c696226650a8687"},
+	{
+		"passwd", "salt", 1,
+		"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+	},
+}
+
+// TestVerifyPasswordAcceptsPBKDF2Hashes checks that passwords hashed before
+// Argon2id still log in, so they can be rehashed.
+func TestVerifyPasswordAcceptsPBKDF2Hashes(t *testing.T) {
+	for _, v := range pbkdf2Vectors {
+		key, err := hex.DecodeString(v.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", v.iterations,
+			base64.RawStdEncoding.EncodeToString([]byte(v.salt)),
+			base64.RawStdEncoding.EncodeToString(key),
+		)
+		if !VerifyPassword(hash, v.password) {
+			t.Errorf("VerifyPassword(%q, %q) = false", hash, v.password)
+		}
+		if VerifyPassword(hash, v.password+"x") {
+			t.Errorf("VerifyPassword(%q, %q) = true", hash, v.password+"x")
+		}
+		if !NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%q) = false", hash)
+		}
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"password",
+		"bcrypt$2$c2FsdA$rk0Mla9rRtMtCt/5KPBt0CowP47zwlHf1uLYWpVHTEM",
+		"pbkdf2-sha256$0$c2FsdA$rk0Mla9rRtMtCt/5KPBt0CowP47zwlHf1uLYWpVHTEM",
+		"pbkdf2-sha256$x$c2FsdA$rk0Mla9rRtMtCt/5KPBt0CowP47zwlHf1uLYWpVHTEM",
+		"pbkdf2-sha256$2$!!$rk0Mla9rRtMtCt/5KPBt0CowP47zwlHf1uLYWpVHTEM",
+		"pbkdf2-sha256$2$c2FsdA$",
+		"argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=0,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=256$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$t=2$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$!!$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$",
+	} {
+		if VerifyPassword(hash, "password") {
+			t.Errorf("VerifyPassword(%q) matched", hash)
+		}
+		if !NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%q) = false", hash)
+		}
+	}
+}
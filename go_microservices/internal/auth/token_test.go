@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// forge builds a token from raw header and payload JSON, signed with issuer.
+func forge(issuer *TokenIssuer, header, payload string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return signingInput + "." + issuer.sign(signingInput)
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	issuer := NewTokenIssuer(testSecret, time.Minute)
+
+	token, err := issuer.Issue(7, "ann@example.com", "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := issuer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != 7 || claims.Email != "ann@example.com" || claims.Role != "staff" {
+		t.Fatalf("claims: got %+v", claims)
+	}
+	if until := time.Until(claims.ExpiresAt); until <= 0 || until > time.Minute {
+		t.Fatalf("expiry: got %v from now", until)
+	}
+}
+
+func TestVerifyRejectsForgedTokens(t *testing.T) {
+	issuer := NewTokenIssuer(testSecret, time.Minute)
+	token, err := issuer.Issue(7, "ann@example.com", "customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	exp := time.Now().Add(time.Minute).Unix()
+	adminPayload := base64.RawURLEncoding.EncodeToString([]byte(
+		`{"sub":"7","email":"ann@example.com","role":"admin","exp":` + strconv.FormatInt(exp, 10) + `}`))
+	otherSignature := []byte(parts[2])
+	if otherSignature[0] == 'A' {
+		otherSignature[0] = 'B'
+	} else {
+		otherSignature[0] = 'A'
+	}
+	other, err := NewTokenIssuer([]byte("another secret of enough length!"), time.Minute).Issue(7, "ann@example.com", "customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not a JWT", "token"},
+		{"missing signature", parts[0] + "." + parts[1]},
+		{"tampered payload", parts[0] + "." + adminPayload + "." + parts[2]},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + string(otherSignature)},
+		{"alg none", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + adminPayload + "."},
+		{"alg none signed", forge(issuer, `{"alg":"none","typ":"JWT"}`, `{"sub":"7","exp":`+strconv.FormatInt(exp, 10)+`}`)},
+		{"wrong secret", other},
+		{"non-numeric sub", forge(issuer, `{"alg":"HS256","typ":"JWT"}`, `{"sub":"ann","exp":`+strconv.FormatInt(exp, 10)+`}`)},
+		{"zero sub", forge(issuer, `{"alg":"HS256","typ":"JWT"}`, `{"sub":"0","exp":`+strconv.FormatInt(exp, 10)+`}`)},
+		{"payload not JSON", forge(issuer, `{"alg":"HS256","typ":"JWT"}`, `sub=7`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := issuer.Verify(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got %+v, %v, want %v", claims, err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsExpiredTokens(t *testing.T) {
+	issuer := NewTokenIssuer(testSecret, -time.Second)
+
+	token, err := issuer.Issue(7, "ann@example.com", "customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Verify(token); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("got %v, want %v", err, ErrTokenExpired)
+	}
+
+	// Expiry is only checked once the signature holds.
+	forged := forge(NewTokenIssuer([]byte("another secret"), time.Minute), `{"alg":"HS256","typ":"JWT"}`, `{"sub":"7","exp":1}`)
+	if _, err := issuer.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired token with a wrong signature: got %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrCacheMiss is returned by Get when the key is not cached.
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache is the storage used by the service layer. Values are JSON encoded.
+type Cache interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}) error
+	Delete(ctx context.Context, keys ...string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Flush(ctx context.Context) error
+
+	// GetOrLoad reads key into dest, calling load on a miss, and reports
+	// whether a value was found.
+	GetOrLoad(ctx context.Context, key string, dest interface{}, load Loader) (bool, error)
+
+	// Generation returns the current cache generation of a namespace. List
+	// keys embed the generation, so bumping it orphans every cached page at
+	// once and the orphans expire with their TTL.
+	Generation(ctx context.Context, namespace string) (int64, error)
+	BumpGeneration(ctx context.Context, namespace string) error
+}
+
+// Loader fetches a value from the source of truth on a cache miss. A nil
+// value means the entity does not exist.
+type Loader func(ctx context.Context) (interface{}, error)
+
+// Options controls entry lifetimes.
+type Options struct {
+	// TTL is how long entries are fresh.
+	TTL time.Duration
+	// StaleTTL is how long read-through entries may be served after TTL
+	// while they are refreshed in the background.
+	StaleTTL time.Duration
+	// NegativeTTL is how long a "not found" result is remembered.
+	NegativeTTL time.Duration
+}
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverNone   = "none"
+)
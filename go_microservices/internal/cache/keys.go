@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	UsersNamespace    = "users"
+	ProductsNamespace = "products"
+)
+
+// Вспомогательные методы для формирования ключей
+func GenerationKey(namespace string) string {
+	return fmt.Sprintf("%s:generation", namespace)
+}
+
+// DeleteVersionKey counts deletes of key for stores that keep the delete
+// version next to the value. The braces put both keys in one Redis Cluster
+// hash slot.
+func DeleteVersionKey(key string) string {
+	return fmt.Sprintf("deleted:{%s}", key)
+}
+
+func UserKey(id int) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+func UserListKey(generation int64, page, limit int) string {
+	return fmt.Sprintf("users:v%d:list:%d:%d", generation, page, limit)
+}
+
+func UserCursorKey(generation int64, cursor string, limit int) string {
+	sum := sha1.Sum([]byte(cursor))
+	return fmt.Sprintf("users:v%d:cursor:%d:%s", generation, limit, hex.EncodeToString(sum[:8]))
+}
+
+func ProductKey(id int) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+// ProductListKey includes a digest of the normalized filter so that every
+// filter combination gets its own cache entry.
+func ProductListKey(generation int64, page, limit int, filterKey string) string {
+	sum := sha1.Sum([]byte(filterKey))
+	return fmt.Sprintf("products:v%d:list:%d:%d:%s", generation, page, limit, hex.EncodeToString(sum[:8]))
+}
+
+func ProductCursorKey(generation int64, cursor string, limit int, filterKey string) string {
+	sum := sha1.Sum([]byte(cursor + "|" + filterKey))
+	return fmt.Sprintf("products:v%d:cursor:%d:%s", generation, limit, hex.EncodeToString(sum[:8]))
+}
+
+// CategoryTreeKey holds the whole category tree, which is small and read as
+// a unit.
+func CategoryTreeKey() string {
+	return "categories:tree"
+}
+
+func OrderKey(id int) string {
+	return fmt.Sprintf("order:%d", id)
+}
+
+// APIKeyKey is keyed by the hash of the key, which is what a request carries.
+func APIKeyKey(keyHash string) string {
+	return fmt.Sprintf("api_key:%s", keyHash)
+}
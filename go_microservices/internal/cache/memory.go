@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-process LRU cache with per-entry TTL. It lets the
+// service run without Redis; entries are not shared between replicas.
+type MemoryCache struct {
+	*ReadThrough
+
+	mu          sync.Mutex
+	ttl         time.Duration
+	maxEntries  int
+	items       map[string]*list.Element
+	order       *list.List
+	generations map[string]int64
+
+	// deletes counts Delete and Flush calls. It serves as the delete version
+	// of every key: keeping one per key would grow with every key ever
+	// deleted, and a load that overlaps any delete merely skips storing.
+	deletes int64
+}
+
+type memoryItem struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+func NewMemoryCache(maxEntries int, opts Options) *MemoryCache {
+	c := &MemoryCache{
+		ttl:         opts.TTL,
+		maxEntries:  maxEntries,
+		items:       make(map[string]*list.Element),
+		order:       list.New(),
+		generations: make(map[string]int64),
+	}
+	c.ReadThrough = NewReadThrough(c, opts)
+	return c
+}
+
+func (c *MemoryCache) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	item := el.Value.(*memoryItem)
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		c.removeElement(el)
+		return nil, ErrCacheMiss
+	}
+
+	c.order.MoveToFront(el)
+	return item.data, nil
+}
+
+func (c *MemoryCache) SetBytes(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(key, data, ttl)
+	return nil
+}
+
+func (c *MemoryCache) setLocked(key string, data []byte, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*memoryItem)
+		item.data = data
+		item.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&memoryItem{key: key, data: data, expiresAt: expiresAt})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *MemoryCache) DeleteVersion(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.deletes, nil
+}
+
+func (c *MemoryCache) SetBytesIfVersion(ctx context.Context, key string, version int64, data []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.deletes != version {
+		return nil
+	}
+	c.setLocked(key, data, ttl)
+	return nil
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, err := c.GetBytes(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(ctx, key, data, WithJitter(c.ttl))
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deletes++
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+	}
+	return nil
+}
+
+func (c *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := c.GetBytes(ctx, key)
+	if err == ErrCacheMiss {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *MemoryCache) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deletes++
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.generations = make(map[string]int64)
+	return nil
+}
+
+func (c *MemoryCache) Generation(ctx context.Context, namespace string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generations[namespace], nil
+}
+
+func (c *MemoryCache) BumpGeneration(ctx context.Context, namespace string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[namespace]++
+	return nil
+}
+
+func (c *MemoryCache) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*memoryItem).key)
+}
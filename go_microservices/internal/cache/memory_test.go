@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsTheLeastRecentlyUsedKey(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(2, testOptions)
+
+	c.Set(ctx, "a", 1)
+	c.Set(ctx, "b", 2)
+	var v int
+	if err := c.Get(ctx, "a", &v); err != nil || v != 1 {
+		t.Fatalf("got %d, %v", v, err)
+	}
+	c.Set(ctx, "c", 3)
+
+	if err := c.Get(ctx, "b", &v); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("the least recently used key is still cached: %v", err)
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if err := c.Get(ctx, key, &v); err != nil || v != want {
+			t.Fatalf("%s: got %d, %v", key, v, err)
+		}
+	}
+}
+
+func TestMemoryCacheExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(0, Options{TTL: time.Millisecond})
+
+	c.Set(ctx, "a", 1)
+	time.Sleep(5 * time.Millisecond)
+	if exists, err := c.Exists(ctx, "a"); err != nil || exists {
+		t.Fatalf("an expired key exists: %v, %v", exists, err)
+	}
+
+	// A zero TTL keeps entries until they are evicted.
+	c = NewMemoryCache(0, Options{})
+	c.Set(ctx, "a", 1)
+	time.Sleep(5 * time.Millisecond)
+	if exists, _ := c.Exists(ctx, "a"); !exists {
+		t.Fatal("a key without a TTL expired")
+	}
+}
+
+func TestMemoryCacheGenerations(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(0, testOptions)
+
+	c.BumpGeneration(ctx, UsersNamespace)
+	c.BumpGeneration(ctx, UsersNamespace)
+	if gen, _ := c.Generation(ctx, UsersNamespace); gen != 2 {
+		t.Fatalf("users generation %d, want 2", gen)
+	}
+	if gen, _ := c.Generation(ctx, ProductsNamespace); gen != 0 {
+		t.Fatalf("products generation %d, want 0", gen)
+	}
+
+	c.Set(ctx, "a", 1)
+	c.Flush(ctx)
+	if gen, _ := c.Generation(ctx, UsersNamespace); gen != 0 {
+		t.Fatalf("users generation %d after a flush", gen)
+	}
+	if exists, _ := c.Exists(ctx, "a"); exists {
+		t.Fatal("a key survived a flush")
+	}
+}
+
+func TestNoopCacheAlwaysLoads(t *testing.T) {
+	ctx := context.Background()
+	c := NewNoopCache()
+	c.Set(ctx, "a", 1)
+
+	var v int
+	if err := c.Get(ctx, "a", &v); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("got %v, want %v", err, ErrCacheMiss)
+	}
+	calls := 0
+	for i := 0; i < 2; i++ {
+		found, err := c.GetOrLoad(ctx, "a", &v, func(ctx context.Context) (interface{}, error) {
+			calls++
+			return 7, nil
+		})
+		if err != nil || !found || v != 7 {
+			t.Fatalf("got %v, %v, %d", found, err, v)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("loaded %d times, want every time", calls)
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// jitterFraction spreads expirations of keys written together over up to
+	// 10% of their TTL.
+	jitterFraction = 10
+
+	// loadTimeout bounds a load shared by several callers. It runs detached
+	// from their contexts, so none of them can cancel it for the others.
+	loadTimeout = 5 * time.Second
+)
+
+// RawStore is the byte-level storage a ReadThrough runs on. GetBytes returns
+// ErrCacheMiss when the key is absent.
+//
+// DeleteVersion returns a number that changes whenever key is deleted, and
+// SetBytesIfVersion stores data only while it still equals version. A load
+// takes the version before reading the source, so the value it read cannot
+// overwrite a Delete made by a concurrent write.
+type RawStore interface {
+	GetBytes(ctx context.Context, key string) ([]byte, error)
+	SetBytes(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	DeleteVersion(ctx context.Context, key string) (int64, error)
+	SetBytesIfVersion(ctx context.Context, key string, version int64, data []byte, ttl time.Duration) error
+}
+
+// ReadThrough implements GetOrLoad on top of a RawStore. Concurrent misses for
+// the same key within the process share a single load, which a caller that
+// gives up does not cancel for the others. Stale entries are
+// returned immediately while one goroutine refreshes them in the background,
+// and "not found" results are remembered for the negative TTL.
+type ReadThrough struct {
+	store RawStore
+	opts  Options
+	group singleflight.Group
+}
+
+func NewReadThrough(store RawStore, opts Options) *ReadThrough {
+	return &ReadThrough{
+		store: store,
+		opts:  opts,
+	}
+}
+
+// entry wraps read-through values with the moment they become stale. Missing
+// marks a tombstone for an entity that does not exist.
+type entry struct {
+	Value      json.RawMessage `json:"v,omitempty"`
+	FreshUntil int64           `json:"f,omitempty"`
+	Missing    bool            `json:"m,omitempty"`
+}
+
+func (rt *ReadThrough) GetOrLoad(ctx context.Context, key string, dest interface{}, load Loader) (bool, error) {
+	data, err := rt.store.GetBytes(ctx, key)
+	if err == nil {
+		var e entry
+		if json.Unmarshal(data, &e) == nil && e.Missing {
+			return false, nil
+		}
+		if e.Value != nil && json.Unmarshal(e.Value, dest) == nil {
+			if time.Now().UnixNano() >= e.FreshUntil {
+				rt.refresh(ctx, key, load)
+			}
+			return true, nil
+		}
+	}
+
+	var res singleflight.Result
+	select {
+	case res = <-rt.load(ctx, key, load):
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	if res.Err != nil {
+		return false, res.Err
+	}
+
+	value := res.Val.(json.RawMessage)
+	if value == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(value, dest)
+}
+
+func (rt *ReadThrough) refresh(ctx context.Context, key string, load Loader) {
+	rt.load(ctx, key, load)
+}
+
+// load starts loading key unless a load of it is already running, and returns
+// a channel that receives the result. The load keeps the values of ctx but
+// not its cancellation.
+func (rt *ReadThrough) load(ctx context.Context, key string, load Loader) <-chan singleflight.Result {
+	ctx = context.WithoutCancel(ctx)
+	return rt.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+		defer cancel()
+		return rt.loadAndStore(ctx, key, load)
+	})
+}
+
+func (rt *ReadThrough) loadAndStore(ctx context.Context, key string, load Loader) (interface{}, error) {
+	// Without a version the result is returned but not stored.
+	version, versionErr := rt.store.DeleteVersion(ctx, key)
+	store := func(data []byte, ttl time.Duration) {
+		if versionErr == nil {
+			rt.store.SetBytesIfVersion(ctx, key, version, data, ttl)
+		}
+	}
+
+	value, err := load(ctx)
+	if err != nil {
+		return json.RawMessage(nil), err
+	}
+	if value == nil {
+		if rt.opts.NegativeTTL > 0 {
+			if data, err := json.Marshal(entry{Missing: true}); err == nil {
+				store(data, WithJitter(rt.opts.NegativeTTL))
+			}
+		}
+		return json.RawMessage(nil), nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return json.RawMessage(nil), err
+	}
+
+	fresh := WithJitter(rt.opts.TTL)
+	data, err := json.Marshal(entry{
+		Value:      raw,
+		FreshUntil: time.Now().Add(fresh).UnixNano(),
+	})
+	if err != nil {
+		return json.RawMessage(nil), err
+	}
+	store(data, fresh+rt.opts.StaleTTL)
+
+	return json.RawMessage(raw), nil
+}
+
+// WithJitter adds up to 10% random spread to ttl.
+func WithJitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(int64(ttl)/jitterFraction+1))
+}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+var testOptions = Options{TTL: time.Minute, StaleTTL: time.Minute, NegativeTTL: time.Minute}
+
+// blockingLoader returns a loader that signals started and then waits for
+// release before returning value.
+func blockingLoader(calls *int32, started chan<- struct{}, release <-chan struct{}, value interface{}) Loader {
+	return func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(calls, 1)
+		started <- struct{}{}
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+}
+
+func TestGetOrLoadCachesValuesAndMisses(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(0, testOptions)
+
+	var calls int32
+	load := func(value interface{}) Loader {
+		return func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return value, nil
+		}
+	}
+
+	var got item
+	if found, err := c.GetOrLoad(ctx, "item:1", &got, load(item{Name: "one"})); err != nil || !found || got.Name != "one" {
+		t.Fatalf("got %v, %v, %+v on a miss", found, err, got)
+	}
+	got = item{}
+	if found, err := c.GetOrLoad(ctx, "item:1", &got, load(item{Name: "other"})); err != nil || !found || got.Name != "one" {
+		t.Fatalf("got %v, %v, %+v on a hit", found, err, got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if found, err := c.GetOrLoad(ctx, "item:2", &got, load(nil)); err != nil || found {
+			t.Fatalf("got %v, %v for a missing item", found, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("the source was read %d times, want once per key", calls)
+	}
+
+	// A failed load is returned and not cached.
+	failure := errors.New("database is down")
+	failing := func(ctx context.Context) (interface{}, error) { return nil, failure }
+	if _, err := c.GetOrLoad(ctx, "item:3", &got, failing); !errors.Is(err, failure) {
+		t.Fatalf("got %v, want %v", err, failure)
+	}
+	if found, err := c.GetOrLoad(ctx, "item:3", &got, load(item{Name: "three"})); err != nil || !found || got.Name != "three" {
+		t.Fatalf("got %v, %v, %+v after a failed load", found, err, got)
+	}
+}
+
+func TestGetOrLoadOutlivesTheCallerThatStartedIt(t *testing.T) {
+	c := NewMemoryCache(0, testOptions)
+
+	var calls int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	load := blockingLoader(&calls, started, release, item{Name: "one"})
+
+	firstCtx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		var got item
+		_, err := c.GetOrLoad(firstCtx, "item:1", &got, load)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan item, 1)
+	go func() {
+		var got item
+		if found, err := c.GetOrLoad(context.Background(), "item:1", &got, load); err != nil || !found {
+			t.Errorf("the second caller got %v, %v", found, err)
+		}
+		second <- got
+	}()
+	// Let the second caller join the load; singleflight cannot be observed.
+	time.Sleep(10 * time.Millisecond)
+
+	// The first caller stops waiting as soon as it gives up.
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("the first caller got %v, want %v", err, context.Canceled)
+	}
+
+	close(release)
+	if got := <-second; got.Name != "one" {
+		t.Fatalf("the second caller got %+v", got)
+	}
+
+	var got item
+	if err := c.Get(context.Background(), "item:1", &got); err != nil {
+		t.Fatalf("the shared load was not cached: %v", err)
+	}
+}
+
+func TestGetOrLoadDoesNotStoreAValueReadBeforeADelete(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(0, testOptions)
+
+	for _, tt := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"value", item{Name: "old"}},
+		{"tombstone", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			started, release := make(chan struct{}, 1), make(chan struct{})
+			done := make(chan error, 1)
+			go func() {
+				var got item
+				_, err := c.GetOrLoad(ctx, "item:1", &got, blockingLoader(&calls, started, release, tt.value))
+				done <- err
+			}()
+			<-started
+
+			// A write lands while the load is still reading.
+			c.Delete(ctx, "item:1")
+			close(release)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			if exists, _ := c.Exists(ctx, "item:1"); exists {
+				t.Fatal("the load stored what it read before the delete")
+			}
+			var got item
+			if found, err := c.GetOrLoad(ctx, "item:1", &got, func(ctx context.Context) (interface{}, error) {
+				return item{Name: "new"}, nil
+			}); err != nil || !found || got.Name != "new" {
+				t.Fatalf("got %v, %v, %+v after the delete", found, err, got)
+			}
+			c.Delete(ctx, "item:1")
+		})
+	}
+}
+
+func TestGetOrLoadRefreshesStaleValues(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCache(0, Options{TTL: time.Nanosecond, StaleTTL: time.Minute})
+
+	var got item
+	if _, err := c.GetOrLoad(ctx, "item:1", &got, func(ctx context.Context) (interface{}, error) {
+		return item{Name: "old"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := make(chan struct{})
+	found, err := c.GetOrLoad(ctx, "item:1", &got, func(ctx context.Context) (interface{}, error) {
+		defer close(refreshed)
+		return item{Name: "new"}, nil
+	})
+	if err != nil || !found || got.Name != "old" {
+		t.Fatalf("got %v, %v, %+v, want the stale value", found, err, got)
+	}
+
+	// The refresh stores its value right after the loader returns.
+	<-refreshed
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		var e entry
+		if c.Get(ctx, "item:1", &e) == nil && string(e.Value) == `{"name":"new"}` {
+			return
+		}
+	}
+	t.Fatal("the stale value was not refreshed")
+}
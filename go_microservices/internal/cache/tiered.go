@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+)
+
+// flushAll is broadcast instead of a key list when the whole cache is flushed.
+const flushAll = "*"
+
+// Invalidator carries key evictions between replicas.
+type Invalidator interface {
+	Publish(ctx context.Context, keys []string) error
+	Subscribe(ctx context.Context, handler func(keys []string))
+}
+
+// Stats holds per-tier hit and miss counters.
+type Stats struct {
+	L1Hits   uint64 `json:"l1_hits"`
+	L1Misses uint64 `json:"l1_misses"`
+	L2Hits   uint64 `json:"l2_hits"`
+	L2Misses uint64 `json:"l2_misses"`
+}
+
+// StatsReporter is implemented by caches that count hits and misses.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// TieredCache serves reads from a short-lived in-process L1 in front of a
+// shared L2. Deletes are broadcast so that every replica evicts its L1 copy;
+// a lost broadcast is bounded by the L1 TTL.
+type TieredCache struct {
+	l1  *MemoryCache
+	l2  Cache
+	bus Invalidator
+
+	l1Lookups, l1Misses atomic.Uint64
+	l2Lookups, l2Misses atomic.Uint64
+}
+
+// NewTieredCache wires l1 in front of l2 and starts listening for evictions
+// published by other replicas until ctx is done.
+func NewTieredCache(ctx context.Context, l1 *MemoryCache, l2 Cache, bus Invalidator) *TieredCache {
+	c := &TieredCache{
+		l1:  l1,
+		l2:  l2,
+		bus: bus,
+	}
+	bus.Subscribe(ctx, c.evictLocal)
+	return c
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string, dest interface{}) error {
+	c.l1Lookups.Add(1)
+	data, err := c.l1.GetBytes(ctx, key)
+	if err == nil {
+		return json.Unmarshal(data, dest)
+	}
+	c.l1Misses.Add(1)
+
+	c.l2Lookups.Add(1)
+	var raw json.RawMessage
+	if err := c.l2.Get(ctx, key, &raw); err != nil {
+		c.l2Misses.Add(1)
+		return err
+	}
+
+	c.l1.Set(ctx, key, raw)
+	return json.Unmarshal(raw, dest)
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value interface{}) error {
+	if err := c.l2.Set(ctx, key, value); err != nil {
+		return err
+	}
+	return c.l1.Set(ctx, key, value)
+}
+
+func (c *TieredCache) Delete(ctx context.Context, keys ...string) error {
+	c.l1.Delete(ctx, keys...)
+	if err := c.l2.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	return c.bus.Publish(ctx, keys)
+}
+
+func (c *TieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, _ := c.l1.Exists(ctx, key); ok {
+		return true, nil
+	}
+	return c.l2.Exists(ctx, key)
+}
+
+func (c *TieredCache) Flush(ctx context.Context) error {
+	c.l1.Flush(ctx)
+	if err := c.l2.Flush(ctx); err != nil {
+		return err
+	}
+	return c.bus.Publish(ctx, []string{flushAll})
+}
+
+// GetOrLoad keeps the read-through semantics of both tiers: L1 loads from L2,
+// and L2 loads from the source of truth. Misses are counted when a tier has to
+// load, including background refreshes of stale entries.
+func (c *TieredCache) GetOrLoad(ctx context.Context, key string, dest interface{}, load Loader) (bool, error) {
+	c.l1Lookups.Add(1)
+	return c.l1.GetOrLoad(ctx, key, dest, func(ctx context.Context) (interface{}, error) {
+		c.l1Misses.Add(1)
+		c.l2Lookups.Add(1)
+
+		var raw json.RawMessage
+		found, err := c.l2.GetOrLoad(ctx, key, &raw, func(ctx context.Context) (interface{}, error) {
+			c.l2Misses.Add(1)
+			return load(ctx)
+		})
+		if err != nil || !found {
+			return nil, err
+		}
+		return raw, nil
+	})
+}
+
+func (c *TieredCache) Generation(ctx context.Context, namespace string) (int64, error) {
+	return c.l2.Generation(ctx, namespace)
+}
+
+func (c *TieredCache) BumpGeneration(ctx context.Context, namespace string) error {
+	return c.l2.BumpGeneration(ctx, namespace)
+}
+
+func (c *TieredCache) Stats() Stats {
+	l1Lookups, l1Misses := c.l1Lookups.Load(), c.l1Misses.Load()
+	l2Lookups, l2Misses := c.l2Lookups.Load(), c.l2Misses.Load()
+	return Stats{
+		L1Hits:   hits(l1Lookups, l1Misses),
+		L1Misses: l1Misses,
+		L2Hits:   hits(l2Lookups, l2Misses),
+		L2Misses: l2Misses,
+	}
+}
+
+// hits derives hits from lookups; background refreshes count as misses
+// without a lookup, so the difference is clamped at zero.
+func hits(lookups, misses uint64) uint64 {
+	if misses > lookups {
+		return 0
+	}
+	return lookups - misses
+}
+
+func (c *TieredCache) evictLocal(keys []string) {
+	ctx := context.Background()
+	for _, key := range keys {
+		if key == flushAll {
+			c.l1.Flush(ctx)
+			return
+		}
+	}
+	c.l1.Delete(ctx, keys...)
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeBus delivers evictions synchronously to every subscriber, the publisher
+// included, as Redis pub/sub does.
+type fakeBus struct {
+	mu       sync.Mutex
+	handlers []func(keys []string)
+}
+
+func (b *fakeBus) Publish(ctx context.Context, keys []string) error {
+	b.mu.Lock()
+	handlers := b.handlers
+	b.mu.Unlock()
+	for _, handler := range handlers {
+		handler(keys)
+	}
+	return nil
+}
+
+func (b *fakeBus) Subscribe(ctx context.Context, handler func(keys []string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// newReplicas returns two tiered caches over one L2 and one bus, as two
+// replicas sharing Redis.
+func newReplicas() (a, b *TieredCache, l2 *MemoryCache) {
+	ctx := context.Background()
+	l2 = NewMemoryCache(0, testOptions)
+	bus := &fakeBus{}
+	a = NewTieredCache(ctx, NewMemoryCache(0, testOptions), l2, bus)
+	b = NewTieredCache(ctx, NewMemoryCache(0, testOptions), l2, bus)
+	return a, b, l2
+}
+
+func TestTieredCacheDeleteEvictsEveryReplica(t *testing.T) {
+	ctx := context.Background()
+	a, b, l2 := newReplicas()
+
+	a.Set(ctx, "a", 1)
+	var v int
+	if err := b.Get(ctx, "a", &v); err != nil || v != 1 {
+		t.Fatalf("replica b got %d, %v", v, err)
+	}
+	// Replica b now serves the key from its L1, even if L2 changes.
+	l2.Set(ctx, "a", 2)
+	if err := b.Get(ctx, "a", &v); err != nil || v != 1 {
+		t.Fatalf("replica b got %d, %v from L1", v, err)
+	}
+
+	if err := a.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]*TieredCache{"a": a, "b": b} {
+		if err := c.Get(ctx, "a", &v); !errors.Is(err, ErrCacheMiss) {
+			t.Fatalf("replica %s got %d, %v after the delete", name, v, err)
+		}
+	}
+
+	b.Set(ctx, "b", 1)
+	a.Get(ctx, "b", &v)
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := a.l1.Exists(ctx, "b"); exists {
+		t.Fatal("a flush on replica b left the L1 of replica a")
+	}
+}
+
+func TestTieredCacheGetOrLoadCountsHitsPerTier(t *testing.T) {
+	ctx := context.Background()
+	a, b, _ := newReplicas()
+	load := func(ctx context.Context) (interface{}, error) { return 1, nil }
+
+	var v int
+	a.GetOrLoad(ctx, "a", &v, load) // misses both tiers
+	a.GetOrLoad(ctx, "a", &v, load) // hits L1
+	b.GetOrLoad(ctx, "a", &v, load) // misses L1, hits L2
+
+	if got, want := a.Stats(), (Stats{L1Hits: 1, L1Misses: 1, L2Misses: 1}); got != want {
+		t.Fatalf("replica a: got %+v, want %+v", got, want)
+	}
+	if got, want := b.Stats(), (Stats{L1Misses: 1, L2Hits: 1}); got != want {
+		t.Fatalf("replica b: got %+v, want %+v", got, want)
+	}
+	if v != 1 {
+		t.Fatalf("got %d", v)
+	}
+}
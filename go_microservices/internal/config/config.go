@@ -22,14 +22,56 @@ type Config struct {
 	DBMaxIdleConns    int
 	DBConnMaxLifetime time.Duration
 
+	//migrations
+	MigrateOnStart bool
+	MigrationsDir  string
+
+	//soft delete
+	SoftDeleteRetention time.Duration
+
+	//pricing
+	BaseCurrency string
+
 	//redis
-	RedisHost     string
-	RedisPort     string
-	RedisPassword string
-	RedisDB       int
-	CacheTTL      time.Duration
+	RedisHost        string
+	RedisPort        string
+	RedisPassword    string
+	RedisDB          int
+	CacheTTL         time.Duration
+	CacheStaleTTL    time.Duration
+	CacheNegativeTTL time.Duration
+	CacheDriver      string
+	CacheMaxEntries  int
+
+	//two-tier cache
+	CacheL1Enabled           bool
+	CacheL1TTL               time.Duration
+	CacheInvalidationChannel string
+
+	//reservations
+	ReservationTTL          time.Duration
+	ReservationReapInterval time.Duration
+
+	//auth
+	JWTSecret         string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	RefreshTokenStore string
+
+	//rate limiting
+	RateLimitDriver         string
+	RateLimitRules          string
+	RateLimitTrustedProxies int
 }
 
+// defaultRateLimitRules limit each route group per kind of caller; see
+// ratelimit.ParseRules for the format.
+const defaultRateLimitRules = "auth:ip=10/1m,auth:user=10/1m," +
+	"read:ip=120/1m,read:user=600/1m,read:api_key=3000/1m," +
+	"export:ip=5/1m,export:user=10/1m,export:api_key=30/1m," +
+	"write:ip=30/1m,write:user=120/1m,write:api_key=1200/1m," +
+	"auth_failure:ip=20/1m"
+
 func Load() *Config {
 	return &Config{
 		//app
@@ -47,12 +89,46 @@ func Load() *Config {
 		DBMaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
 		DBConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
 
+		//migrations
+		MigrateOnStart: getEnvAsBool("MIGRATE_ON_START", false),
+		MigrationsDir:  getEnv("MIGRATIONS_DIR", "migrations"),
+
+		//soft delete
+		SoftDeleteRetention: getEnvAsDuration("SOFT_DELETE_RETENTION", 30*24*time.Hour),
+
+		//pricing
+		BaseCurrency: getEnv("BASE_CURRENCY", "USD"),
+
 		//redis
-		RedisHost:     getEnv("REDIS_HOST", "localhost"),
-		RedisPort:     getEnv("REDIS_PORT", "6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       getEnvAsInt("REDIS_DB", 0),
-		CacheTTL:      getEnvAsDuration("CACHE_TTL", 5*time.Minute),
+		RedisHost:        getEnv("REDIS_HOST", "localhost"),
+		RedisPort:        getEnv("REDIS_PORT", "6379"),
+		RedisPassword:    getEnv("REDIS_PASSWORD", ""),
+		RedisDB:          getEnvAsInt("REDIS_DB", 0),
+		CacheTTL:         getEnvAsDuration("CACHE_TTL", 5*time.Minute),
+		CacheStaleTTL:    getEnvAsDuration("CACHE_STALE_TTL", time.Minute),
+		CacheNegativeTTL: getEnvAsDuration("CACHE_NEGATIVE_TTL", 30*time.Second),
+		CacheDriver:      getEnv("CACHE_DRIVER", "redis"),
+		CacheMaxEntries:  getEnvAsInt("CACHE_MAX_ENTRIES", 10000),
+
+		//two-tier cache
+		CacheL1Enabled:           getEnvAsBool("CACHE_L1_ENABLED", false),
+		CacheL1TTL:               getEnvAsDuration("CACHE_L1_TTL", 30*time.Second),
+		CacheInvalidationChannel: getEnv("CACHE_INVALIDATION_CHANNEL", "cache:invalidate"),
+
+		//reservations
+		ReservationTTL:          getEnvAsDuration("RESERVATION_TTL", 15*time.Minute),
+		ReservationReapInterval: getEnvAsDuration("RESERVATION_REAP_INTERVAL", time.Minute),
+
+		//auth
+		JWTSecret:         getEnv("JWT_SECRET", ""),
+		AccessTokenTTL:    getEnvAsDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:   getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		RefreshTokenStore: getEnv("REFRESH_TOKEN_STORE", "redis"),
+
+		//rate limiting
+		RateLimitDriver:         getEnv("RATE_LIMIT_DRIVER", "redis"),
+		RateLimitRules:          getEnv("RATE_LIMIT_RULES", defaultRateLimitRules),
+		RateLimitTrustedProxies: getEnvAsInt("RATE_LIMIT_TRUSTED_PROXIES", 0),
 	}
 }
 
@@ -85,6 +161,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
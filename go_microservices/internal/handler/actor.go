@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"go_microservices/internal/service"
+)
+
+// requestActor identifies the caller for audit records: a user by email and
+// an API key by ID. It is derived from the credentials only, so a caller
+// cannot record changes under another name.
+func requestActor(r *http.Request) string {
+	if principal := service.PrincipalFromContext(r.Context()); principal != nil {
+		if principal.IsAPIKey() {
+			return "api-key:" + strconv.Itoa(principal.APIKeyID)
+		}
+		return principal.Email
+	}
+	return "anonymous"
+}
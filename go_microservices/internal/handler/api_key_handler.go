@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// RegisterRoutes registers the API key management routes. Only admins manage
+// keys; API keys cannot create or revoke other keys.
+func (h *APIKeyHandler) RegisterRoutes(mux Router) {
+	admin := hasRole(models.RoleAdmin)
+
+	mux.HandleFunc("POST /admin/api-keys", authorize(admin, h.createAPIKey))
+	mux.HandleFunc("GET /admin/api-keys", authorize(admin, h.listAPIKeys))
+	mux.HandleFunc("DELETE /admin/api-keys/{id}", authorize(admin, h.revokeAPIKey))
+}
+
+func (h *APIKeyHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	createdBy := service.PrincipalFromContext(r.Context()).UserID
+	apiKey, err := h.apiKeyService.Create(ctx, &req, &createdBy)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, apiKey)
+}
+
+func (h *APIKeyHandler) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	apiKeys, err := h.apiKeyService.GetAll(ctx)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"data": apiKeys,
+	})
+}
+
+func (h *APIKeyHandler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.apiKeyService.Revoke(ctx, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIKeyHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func (h *APIKeyHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithProblem(w, code, message)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+type AuthHandler struct {
+	authService *service.AuthService
+}
+
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		authService: authService,
+	}
+}
+
+func (h *AuthHandler) RegisterRoutes(mux Router) {
+	mux.HandleFunc("POST /auth/register", h.register)
+	mux.HandleFunc("POST /auth/login", h.login)
+	mux.HandleFunc("POST /auth/refresh", h.refresh)
+	mux.HandleFunc("POST /auth/logout", h.logout)
+}
+
+func (h *AuthHandler) register(w http.ResponseWriter, r *http.Request) {
+	var req models.RegisterRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	response, err := h.authService.Register(ctx, &req)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tokens, err := h.authService.Login(ctx, &req)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tokens, err := h.authService.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) logout(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	if err := h.authService.Logout(ctx, req.RefreshToken); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
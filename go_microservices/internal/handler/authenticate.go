@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+// Authenticate identifies the caller of a request from its Authorization
+// header, "Bearer <access token>" for users and "ApiKey <key>" for services,
+// and puts it into the request context as a service.Principal. Requests
+// without the header pass through anonymously; the routes decide whether that
+// is enough. Credentials that are present but invalid or expired are
+// rejected, so that a client notices it has to refresh them, and reported to
+// LimitAuthFailures.
+func Authenticate(tokens *auth.TokenIssuer, apiKeys *service.APIKeyService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, credentials, _ := strings.Cut(header, " ")
+		credentials = strings.TrimSpace(credentials)
+
+		var principal *service.Principal
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			claims, err := tokens.Verify(credentials)
+			if err != nil {
+				code := "invalid_token"
+				if errors.Is(err, auth.ErrTokenExpired) {
+					code = "token_expired"
+				}
+				reportAuthFailure(r)
+				respondUnauthenticated(w, code, err.Error())
+				return
+			}
+			principal = &service.Principal{UserID: claims.UserID, Email: claims.Email, Role: claims.Role}
+
+		case strings.EqualFold(scheme, "ApiKey"):
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			apiKey, err := apiKeys.Authenticate(ctx, credentials)
+			cancel()
+			if err != nil {
+				if errors.Is(err, service.ErrInvalidAPIKey) {
+					reportAuthFailure(r)
+				}
+				w.Header().Set("WWW-Authenticate", "ApiKey")
+				respondWithServiceError(w, err)
+				return
+			}
+			principal = &service.Principal{APIKeyID: apiKey.ID, Scopes: apiKey.Scopes}
+
+		default:
+			reportAuthFailure(r)
+			respondUnauthenticated(w, "invalid_token", "unsupported authorization scheme")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(service.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// respondUnauthenticated sends a 401 with the challenge RFC 6750 asks for.
+func respondUnauthenticated(w http.ResponseWriter, code, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	writeProblem(w, models.Problem{Status: http.StatusUnauthorized, Code: code, Detail: detail})
+}
@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+type CategoryHandler struct {
+	categoryService *service.CategoryService
+	products        *ProductHandler
+}
+
+// NewCategoryHandler creates the handler. Products of a category are listed
+// the same way as GET /products, so productService is needed too.
+func NewCategoryHandler(categoryService *service.CategoryService, productService *service.ProductService) *CategoryHandler {
+	return &CategoryHandler{
+		categoryService: categoryService,
+		products:        NewProductHandler(productService),
+	}
+}
+
+// RegisterRoutes registers the category routes, each with the policy that
+// decides who may call it. Categories are public; staff manage them like
+// products, and deleted products are listed for admins only.
+func (h *CategoryHandler) RegisterRoutes(mux Router) {
+	write := hasRole(models.RoleStaff, models.ScopeProductsWrite)
+	admin := hasRole(models.RoleAdmin)
+
+	mux.HandleFunc("GET /categories", authorize(anyone, h.getTree))
+	mux.HandleFunc("POST /categories", authorize(write, h.createCategory))
+	mux.HandleFunc("GET /categories/{id}", authorize(anyone, h.getCategory))
+	mux.HandleFunc("PUT /categories/{id}", authorize(write, h.updateCategory))
+	mux.HandleFunc("DELETE /categories/{id}", authorize(write, h.deleteCategory))
+	mux.HandleFunc("GET /categories/{id}/products", authorize(deletedRequires(admin, anyone), h.listProducts))
+	mux.HandleFunc("GET /products/{id}/categories", authorize(anyone, h.getProductCategories))
+	mux.HandleFunc("PUT /products/{id}/categories", authorize(write, h.setProductCategories))
+}
+
+func (h *CategoryHandler) getTree(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tree, err := h.categoryService.Tree(ctx)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{"data": tree})
+}
+
+func (h *CategoryHandler) createCategory(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCategoryRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	category, err := h.categoryService.Create(ctx, &req)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) getCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	category, err := h.categoryService.GetByID(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if category == nil {
+		respondWithServiceError(w, service.ErrCategoryNotFound)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, category)
+}
+
+func (h *CategoryHandler) updateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	var req models.UpdateCategoryRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	category, err := h.categoryService.Update(ctx, id, &req)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, category)
+}
+
+func (h *CategoryHandler) deleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	err = h.categoryService.Delete(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listProducts lists the products of a category and of all its
+// subcategories, with the filters and pagination of GET /products.
+func (h *CategoryHandler) listProducts(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	filter, err := parseProductFilter(r, h.products.productService.BaseCurrency())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Category = &id
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	category, err := h.categoryService.GetByID(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if category == nil {
+		respondWithServiceError(w, service.ErrCategoryNotFound)
+		return
+	}
+
+	h.products.listFiltered(w, r, filter)
+}
+
+func (h *CategoryHandler) getProductCategories(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	categories, err := h.categoryService.ProductCategories(ctx, productID)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{"data": categories})
+}
+
+// setProductCategories replaces the categories of a product with the ones in
+// category_ids; an empty list unlinks it from all of them.
+func (h *CategoryHandler) setProductCategories(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.SetProductCategoriesRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	categories, err := h.categoryService.SetProductCategories(ctx, productID, req.CategoryIDs)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]interface{}{"data": categories})
+}
+
+func (h *CategoryHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func (h *CategoryHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithProblem(w, code, message)
+}
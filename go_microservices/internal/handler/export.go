@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_microservices/internal/models"
+)
+
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "ndjson"
+
+	// exportFlushRows is how often an export pushes buffered rows to the
+	// client.
+	exportFlushRows = 500
+)
+
+var (
+	userCSVHeader = []string{
+		"id", "name", "email", "version", "created_at", "updated_at", "deleted_at",
+	}
+	productCSVHeader = []string{
+		"id", "name", "description", "price", "currency", "stock", "reserved", "available",
+		"version", "created_at", "updated_at", "deleted_at",
+	}
+)
+
+// exportFormat picks the export format from ?format= or, failing that, the
+// Accept header. NDJSON is the default.
+func exportFormat(r *http.Request) (string, error) {
+	switch format := strings.ToLower(r.URL.Query().Get("format")); format {
+	case exportCSV, exportNDJSON:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unsupported export format %q", format)
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return exportCSV, nil
+		case "application/x-ndjson", "application/ndjson":
+			return exportNDJSON, nil
+		}
+	}
+	return exportNDJSON, nil
+}
+
+// exportFilter parses ?updated_since (RFC 3339) and ?include_deleted.
+func exportFilter(r *http.Request) (models.ExportFilter, error) {
+	filter := models.ExportFilter{IncludeDeleted: includeDeleted(r)}
+	if v := r.URL.Query().Get("updated_since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("updated_since must be an RFC 3339 timestamp")
+		}
+		filter.UpdatedSince = &since
+	}
+	return filter, nil
+}
+
+// exportWriter streams rows to the client as CSV or NDJSON. The response
+// starts with the first row, so an error before then can still be reported
+// with a proper status.
+type exportWriter struct {
+	w       http.ResponseWriter
+	format  string
+	name    string
+	header  []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	rows    int
+	started bool
+}
+
+func newExportWriter(w http.ResponseWriter, format, name string, header []string) *exportWriter {
+	return &exportWriter{w: w, format: format, name: name, header: header}
+}
+
+func (e *exportWriter) start() error {
+	e.started = true
+
+	// Exports outlive the server's write timeout; they end when the rows do
+	// or the client goes away.
+	http.NewResponseController(e.w).SetWriteDeadline(time.Time{})
+
+	if e.format == exportCSV {
+		e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		e.w.Header().Set("Content-Disposition", `attachment; filename="`+e.name+`.csv"`)
+		e.w.WriteHeader(http.StatusOK)
+		e.csv = csv.NewWriter(e.w)
+		return e.csv.Write(e.header)
+	}
+
+	e.w.Header().Set("Content-Type", "application/x-ndjson")
+	e.w.Header().Set("Content-Disposition", `attachment; filename="`+e.name+`.ndjson"`)
+	e.w.WriteHeader(http.StatusOK)
+	e.json = json.NewEncoder(e.w)
+	return nil
+}
+
+// write sends one row: v as a JSON line, or record() as a CSV record.
+func (e *exportWriter) write(v interface{}, record func() []string) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if e.format == exportCSV {
+		err = e.csv.Write(record())
+	} else {
+		err = e.json.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// finish completes an export, which may have had no rows at all.
+func (e *exportWriter) finish() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(e.w).Flush()
+}
+
+func userCSVRecord(u models.User) []string {
+	return []string{
+		strconv.Itoa(u.ID), u.Name, u.Email, strconv.Itoa(u.Version),
+		formatExportTime(&u.CreatedAt), formatExportTime(&u.UpdatedAt), formatExportTime(u.DeletedAt),
+	}
+}
+
+func productCSVRecord(p models.Product) []string {
+	return []string{
+		strconv.Itoa(p.ID), p.Name, p.Description,
+		p.Price.Decimal(), p.Price.Currency,
+		strconv.Itoa(p.Stock), strconv.Itoa(p.Reserved), strconv.Itoa(p.Available),
+		strconv.Itoa(p.Version),
+		formatExportTime(&p.CreatedAt), formatExportTime(&p.UpdatedAt), formatExportTime(p.DeletedAt),
+	}
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,261 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/cache"
+	"go_microservices/internal/handler"
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository/memory"
+	"go_microservices/internal/service"
+)
+
+// testServer serves the user, product, category, order and reservation
+// routes over one in-memory store behind the real authentication middleware.
+type testServer struct {
+	*httptest.Server
+	tokens *auth.TokenIssuer
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepository(store)
+	productRepo := memory.NewProductRepository(store)
+	movementRepo := memory.NewStockMovementRepository(store)
+	c := cache.NewMemoryCache(100, cache.Options{TTL: time.Minute, NegativeTTL: time.Minute})
+	tokens := auth.NewTokenIssuer([]byte("0123456789abcdef0123456789abcdef"), time.Minute)
+
+	mux := http.NewServeMux()
+	handler.NewUserHandler(service.NewUserService(userRepo, c)).RegisterRoutes(mux)
+	productService := service.NewProductService(productRepo, movementRepo, c, "USD")
+	handler.NewProductHandler(productService).RegisterRoutes(mux)
+	handler.NewCategoryHandler(
+		service.NewCategoryService(memory.NewCategoryRepository(store), c), productService,
+	).RegisterRoutes(mux)
+	handler.NewOrderHandler(service.NewOrderService(
+		memory.NewOrderRepository(store), productRepo, userRepo, movementRepo, c,
+	)).RegisterRoutes(mux)
+	handler.NewReservationHandler(service.NewReservationService(
+		memory.NewReservationRepository(store), productRepo, movementRepo, c, time.Minute,
+	)).RegisterRoutes(mux)
+	apiKeys := service.NewAPIKeyService(memory.NewAPIKeyRepository(store), c)
+
+	srv := httptest.NewServer(handler.Authenticate(tokens, apiKeys, mux))
+	t.Cleanup(srv.Close)
+	return &testServer{Server: srv, tokens: tokens}
+}
+
+// as returns the Authorization header of a user with the given role.
+func (s *testServer) as(t *testing.T, userID int, role string) string {
+	t.Helper()
+
+	token, err := s.tokens.Issue(userID, "test@example.com", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+// do sends a request and returns the response with its body read. Headers
+// come in name, value pairs.
+func (s *testServer) do(t *testing.T, method, path, body string, headers ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// expectProblem checks that the response is a problem with the given status
+// and code.
+func expectProblem(t *testing.T, resp *http.Response, body string, status int, code string) {
+	t.Helper()
+
+	var problem models.Problem
+	if err := json.Unmarshal([]byte(body), &problem); err != nil {
+		t.Fatalf("decoding %q: %v", body, err)
+	}
+	if resp.StatusCode != status || problem.Status != status || problem.Code != code {
+		t.Fatalf("got %d %q, want %d %q: %s", resp.StatusCode, problem.Code, status, code, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+}
+
+func TestCreateUserRejectsTakenEmail(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.as(t, 1, models.RoleAdmin)
+
+	resp, body := s.do(t, "POST", "/users", `{"name":"Ann","email":"ann@example.com"}`, "Authorization", admin)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first create: got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = s.do(t, "POST", "/users", `{"name":"Other Ann","email":"ann@example.com"}`, "Authorization", admin)
+	expectProblem(t, resp, body, http.StatusConflict, "email_taken")
+}
+
+func TestDeleteMissingProduct(t *testing.T) {
+	s := newTestServer(t)
+
+	resp, body := s.do(t, "DELETE", "/products/42", "", "Authorization", s.as(t, 1, models.RoleAdmin))
+	expectProblem(t, resp, body, http.StatusNotFound, "product_not_found")
+}
+
+func TestProductVersions(t *testing.T) {
+	s := newTestServer(t)
+	staff := s.as(t, 1, models.RoleStaff)
+
+	resp, body := s.do(t, "POST", "/products", `{"name":"Widget","price":{"amount":"2.50","currency":"USD"},"stock":5}`,
+		"Authorization", staff)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") != `"1"` {
+		t.Fatalf("create: got %d, ETag %s: %s", resp.StatusCode, resp.Header.Get("ETag"), body)
+	}
+	var created models.Product
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("create: created_at %v, updated_at %v", created.CreatedAt, created.UpdatedAt)
+	}
+
+	update := `{"name":"Widget","price":{"amount":"3.00","currency":"USD"},"stock":5}`
+	resp, body = s.do(t, "PUT", "/products/1", update, "Authorization", staff, "If-Match", `"1"`)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"2"` {
+		t.Fatalf("update: got %d, ETag %s: %s", resp.StatusCode, resp.Header.Get("ETag"), body)
+	}
+	var updated models.Product
+	if err := json.Unmarshal([]byte(body), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 || !updated.CreatedAt.Equal(created.CreatedAt) || updated.UpdatedAt.Before(created.UpdatedAt) {
+		t.Fatalf("update: got %+v", updated)
+	}
+
+	resp, body = s.do(t, "PUT", "/products/1", update, "Authorization", staff, "If-Match", `"1"`)
+	expectProblem(t, resp, body, http.StatusPreconditionFailed, "version_mismatch")
+}
+
+func TestUpdateStockIsConditional(t *testing.T) {
+	s := newTestServer(t)
+	staff := s.as(t, 1, models.RoleStaff)
+
+	resp, body := s.do(t, "POST", "/products", `{"name":"Widget","price":{"amount":"2.50","currency":"USD"},"stock":5}`,
+		"Authorization", staff)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = s.do(t, "PATCH", "/products/1/stock", `{"quantity":3}`, "Authorization", staff)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("first sale: got %d: %s", resp.StatusCode, body)
+	}
+	resp, body = s.do(t, "PATCH", "/products/1/stock", `{"quantity":3}`, "Authorization", staff)
+	expectProblem(t, resp, body, http.StatusConflict, "insufficient_stock")
+
+	resp, body = s.do(t, "GET", "/products/1", "")
+	var product models.Product
+	if err := json.Unmarshal([]byte(body), &product); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || product.Stock != 2 {
+		t.Fatalf("after one sale: got %d, stock %d", resp.StatusCode, product.Stock)
+	}
+}
+
+func TestWritesRequireCredentials(t *testing.T) {
+	s := newTestServer(t)
+
+	resp, body := s.do(t, "POST", "/orders", `{"user_id":1,"items":[{"product_id":1,"quantity":1}]}`)
+	expectProblem(t, resp, body, http.StatusUnauthorized, "unauthenticated")
+
+	resp, body = s.do(t, "GET", "/users/2/orders", "", "Authorization", s.as(t, 1, models.RoleCustomer))
+	expectProblem(t, resp, body, http.StatusForbidden, "not_owner")
+}
+
+func TestDeletedProductsRequireAdmin(t *testing.T) {
+	s := newTestServer(t)
+	staff := s.as(t, 1, models.RoleStaff)
+	admin := s.as(t, 2, models.RoleAdmin)
+
+	resp, body := s.do(t, "POST", "/products", `{"name":"Widget","price":{"amount":"2.50","currency":"USD"},"stock":5}`,
+		"Authorization", staff)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", resp.StatusCode, body)
+	}
+	resp, body = s.do(t, "DELETE", "/products/1", "", "Authorization", admin)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: got %d: %s", resp.StatusCode, body)
+	}
+
+	for _, path := range []string{
+		"/products?include_deleted=true",
+		"/products?include_deleted=true&cursor=",
+		"/products/1?include_deleted=true",
+		"/categories/1/products?include_deleted=true",
+	} {
+		resp, body = s.do(t, "GET", path, "")
+		expectProblem(t, resp, body, http.StatusUnauthorized, "unauthenticated")
+
+		resp, body = s.do(t, "GET", path, "", "Authorization", staff)
+		expectProblem(t, resp, body, http.StatusForbidden, "insufficient_role")
+	}
+
+	resp, body = s.do(t, "GET", "/products", "")
+	if resp.StatusCode != http.StatusOK || strings.Contains(body, "Widget") {
+		t.Fatalf("anonymous list: got %d: %s", resp.StatusCode, body)
+	}
+	resp, body = s.do(t, "GET", "/products?include_deleted=true", "", "Authorization", admin)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `"deleted_at":"`) {
+		t.Fatalf("admin list with deleted: got %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestReservationTTLIsBounded(t *testing.T) {
+	s := newTestServer(t)
+	staff := s.as(t, 1, models.RoleStaff)
+
+	resp, body := s.do(t, "POST", "/products", `{"name":"Widget","price":{"amount":"2.50","currency":"USD"},"stock":5}`,
+		"Authorization", staff)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: got %d: %s", resp.StatusCode, body)
+	}
+
+	resp, body = s.do(t, "POST", "/products/1/reservations", `{"quantity":1,"ttl_seconds":1000000000}`,
+		"Authorization", staff)
+	expectProblem(t, resp, body, http.StatusUnprocessableEntity, "validation_failed")
+
+	resp, body = s.do(t, "POST", "/products/1/reservations", `{"quantity":1,"ttl_seconds":86400}`,
+		"Authorization", staff)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("reserve for a day: got %d: %s", resp.StatusCode, body)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+type OrderHandler struct {
+	orderService *service.OrderService
+}
+
+func NewOrderHandler(orderService *service.OrderService) *OrderHandler {
+	return &OrderHandler{
+		orderService: orderService,
+	}
+}
+
+// RegisterRoutes registers the order routes, each with the policy that
+// decides who may call it. Customers may only place and see their own orders;
+// who owns a single order is checked once it is loaded.
+func (h *OrderHandler) RegisterRoutes(mux Router) {
+	user := hasRole(models.RoleCustomer)
+
+	mux.HandleFunc("POST /orders", authorize(user, h.createOrder))
+	mux.HandleFunc("GET /orders/{id}", authorize(user, h.getOrder))
+	mux.HandleFunc("GET /users/{id}/orders", authorize(selfOrRole(models.RoleStaff, models.ScopeUsersRead), h.listUserOrders))
+}
+
+func (h *OrderHandler) createOrder(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrderRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	// Customers order for themselves; staff may order on behalf of any user
+	// and default to themselves too.
+	principal := service.PrincipalFromContext(r.Context())
+	if req.UserID == 0 || !models.RoleIncludes(principal.Role, models.RoleStaff) {
+		req.UserID = principal.UserID
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	order, err := h.orderService.Create(ctx, &req)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, order)
+}
+
+func (h *OrderHandler) getOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	order, err := h.orderService.GetByID(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if order == nil {
+		respondWithServiceError(w, service.ErrOrderNotFound)
+		return
+	}
+	if denial := ownerDenial(service.PrincipalFromContext(r.Context()), order.UserID, models.RoleStaff); denial != nil {
+		respondWithDenial(w, denial)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, order)
+}
+
+func (h *OrderHandler) listUserOrders(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	orders, err := h.orderService.GetByUserID(ctx, userID, page, limit)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	total, _ := h.orderService.CountByUserID(ctx, userID)
+
+	response := map[string]interface{}{
+		"data":  orders,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}
+
+	h.respondWithJSON(w, http.StatusOK, response)
+}
+
+func (h *OrderHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func (h *OrderHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithProblem(w, code, message)
+}
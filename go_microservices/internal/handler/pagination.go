@@ -0,0 +1,9 @@
+package handler
+
+// nullableString renders an empty cursor as JSON null.
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+)
+
+// mergePatchContentType is the media type of JSON Merge Patch (RFC 7396).
+const mergePatchContentType = "application/merge-patch+json"
+
+// isMergePatch reports whether the request body is a merge patch. Plain JSON
+// is accepted too, since our resources are flat objects and both parse the
+// same way.
+func isMergePatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && (mediaType == mergePatchContentType || mediaType == "application/json")
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+// A Policy decides whether the caller may perform the action of a route. It
+// returns nil to allow the request or the reason to deny it. principal is nil
+// for an anonymous caller.
+type Policy func(r *http.Request, principal *service.Principal) *Denial
+
+// Denial is why a policy refused a request. Code is stable for clients to
+// match on; Reason is shown to the caller.
+type Denial struct {
+	Code   string
+	Reason string
+}
+
+// authorize runs next only if policy allows the request. A denied anonymous
+// caller gets 401, since logging in may help; anybody else gets 403.
+func authorize(policy Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := service.PrincipalFromContext(r.Context())
+		denial := policy(r, principal)
+		if denial == nil {
+			next(w, r)
+			return
+		}
+
+		if principal == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer, ApiKey")
+			writeProblem(w, models.Problem{
+				Status: http.StatusUnauthorized,
+				Code:   "unauthenticated",
+				Detail: "authentication required: " + denial.Reason,
+			})
+			return
+		}
+		respondWithDenial(w, denial)
+	}
+}
+
+// anyone allows every caller, including anonymous ones.
+func anyone(r *http.Request, principal *service.Principal) *Denial {
+	return nil
+}
+
+// authenticated allows every logged-in caller.
+func authenticated(r *http.Request, principal *service.Principal) *Denial {
+	if principal == nil {
+		return &Denial{Code: "unauthenticated", Reason: "this action requires a logged-in user"}
+	}
+	return nil
+}
+
+// hasRole allows users whose role includes role and API keys with any of
+// scopes. Without scopes, API keys are not allowed at all.
+func hasRole(role string, scopes ...string) Policy {
+	return func(r *http.Request, principal *service.Principal) *Denial {
+		if principal != nil && principal.IsAPIKey() {
+			return scopeDenial(principal, scopes)
+		}
+		if principal == nil || !models.RoleIncludes(principal.Role, role) {
+			return &Denial{
+				Code:   "insufficient_role",
+				Reason: fmt.Sprintf("this action requires the %s role", role),
+			}
+		}
+		return nil
+	}
+}
+
+// selfOrRole allows the user named by the {id} of the route, users whose
+// role includes role and API keys with any of scopes.
+func selfOrRole(role string, scopes ...string) Policy {
+	return func(r *http.Request, principal *service.Principal) *Denial {
+		if denial := authenticated(r, principal); denial != nil {
+			return denial
+		}
+		if principal.IsAPIKey() {
+			return scopeDenial(principal, scopes)
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			id = 0
+		}
+		return ownerDenial(principal, id, role)
+	}
+}
+
+// ownerDenial allows a user to access records that belong to userID if it is
+// that user or its role includes role. Handlers use it for records whose owner
+// is only known after loading them, such as orders.
+func ownerDenial(principal *service.Principal, userID int, role string) *Denial {
+	if models.RoleIncludes(principal.Role, role) || (userID != 0 && userID == principal.UserID) {
+		return nil
+	}
+	return &Denial{
+		Code:   "not_owner",
+		Reason: fmt.Sprintf("users can only access their own records without the %s role", role),
+	}
+}
+
+// respondWithDenial reports a denial found by a handler after the route's
+// policy allowed the request.
+func respondWithDenial(w http.ResponseWriter, denial *Denial) {
+	writeProblem(w, models.Problem{Status: http.StatusForbidden, Code: denial.Code, Detail: denial.Reason})
+}
+
+// deletedRequires applies policy and, for requests that ask for soft-deleted
+// records with ?include_deleted, also required.
+func deletedRequires(required, policy Policy) Policy {
+	return func(r *http.Request, principal *service.Principal) *Denial {
+		if denial := policy(r, principal); denial != nil {
+			return denial
+		}
+		if !includeDeleted(r) {
+			return nil
+		}
+		if denial := required(r, principal); denial != nil {
+			denial.Reason = "include_deleted: " + denial.Reason
+			return denial
+		}
+		return nil
+	}
+}
+
+// scopeDenial allows an API key that has any of scopes.
+func scopeDenial(principal *service.Principal, scopes []string) *Denial {
+	for _, scope := range scopes {
+		if principal.HasScope(scope) {
+			return nil
+		}
+	}
+	if len(scopes) == 0 {
+		return &Denial{Code: "insufficient_scope", Reason: "this action is not available to API keys"}
+	}
+	return &Denial{
+		Code:   "insufficient_scope",
+		Reason: fmt.Sprintf("this action requires the %s scope", strings.Join(scopes, " or ")),
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+// problemContentType is the media type of RFC 7807 problem details.
+const problemContentType = "application/problem+json"
+
+// kindStatus maps the kinds of domain errors to response statuses.
+var kindStatus = map[service.Kind]int{
+	service.KindValidation:      http.StatusUnprocessableEntity,
+	service.KindNotFound:        http.StatusNotFound,
+	service.KindConflict:        http.StatusConflict,
+	service.KindPrecondition:    http.StatusPreconditionFailed,
+	service.KindUnauthenticated: http.StatusUnauthorized,
+}
+
+// respondWithServiceError translates an error returned by a service into a
+// problem response. Domain errors carry their own code; anything else is an
+// internal failure, which is logged and not shown to the client.
+func respondWithServiceError(w http.ResponseWriter, err error) {
+	var domainErr *service.Error
+	switch {
+	case errors.As(err, &domainErr):
+		writeProblem(w, models.Problem{
+			Status: kindStatus[domainErr.Kind],
+			Code:   domainErr.Code,
+			Detail: err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidCursor):
+		writeProblem(w, models.Problem{Status: http.StatusBadRequest, Code: "invalid_cursor", Detail: err.Error()})
+	default:
+		log.Printf("Request failed: %v", err)
+		writeProblem(w, models.Problem{Status: http.StatusInternalServerError, Code: "internal_error"})
+	}
+}
+
+// respondWithProblem reports an error found by a handler itself, such as a
+// malformed ID. The code is derived from the status, e.g. "bad_request".
+func respondWithProblem(w http.ResponseWriter, status int, detail string) {
+	writeProblem(w, models.Problem{Status: status, Detail: detail})
+}
+
+// writeProblem sends p, filling in the members it leaves empty.
+func writeProblem(w http.ResponseWriter, p models.Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Code == "" {
+		p.Code = strings.ToLower(strings.ReplaceAll(http.StatusText(p.Status), " ", "_"))
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -2,8 +2,9 @@ package handler
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,29 +23,74 @@ func NewProductHandler(productService *service.ProductService) *ProductHandler {
 	}
 }
 
-func (h *ProductHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /products", h.listProducts)
-	mux.HandleFunc("POST /products", h.createProduct)
-	mux.HandleFunc("GET /products/{id}", h.getProduct)
-	mux.HandleFunc("PUT /products/{id}", h.updateProduct)
-	mux.HandleFunc("DELETE /products/{id}", h.deleteProduct)
-	mux.HandleFunc("PATCH /products/{id}/stock", h.updateStock)
+// RegisterRoutes registers the product routes, each with the policy that
+// decides who may call it. The catalog is public; staff manage products and
+// stock, and only admins delete them and see deleted ones.
+func (h *ProductHandler) RegisterRoutes(mux Router) {
+	read := hasRole(models.RoleStaff, models.ScopeProductsRead)
+	write := hasRole(models.RoleStaff, models.ScopeProductsWrite)
+	stock := hasRole(models.RoleStaff, models.ScopeStockWrite)
+	admin := hasRole(models.RoleAdmin)
+	public := deletedRequires(admin, anyone)
+
+	mux.HandleFunc("GET /products", authorize(public, h.listProducts))
+	mux.HandleFunc("GET /products/export", authorize(deletedRequires(admin, read), h.exportProducts))
+	mux.HandleFunc("POST /products", authorize(write, h.createProduct))
+	mux.HandleFunc("POST /products/import", authorize(write, h.importProducts))
+	mux.HandleFunc("GET /products/{id}", authorize(public, h.getProduct))
+	mux.HandleFunc("PUT /products/{id}", authorize(write, h.updateProduct))
+	mux.HandleFunc("PATCH /products/{id}", authorize(write, h.patchProduct))
+	mux.HandleFunc("DELETE /products/{id}", authorize(admin, h.deleteProduct))
+	mux.HandleFunc("POST /products/{id}/restore", authorize(admin, h.restoreProduct))
+	mux.HandleFunc("PUT /products/{id}/prices", authorize(write, h.setPrice))
+	mux.HandleFunc("DELETE /products/{id}/prices/{currency}", authorize(write, h.deletePrice))
+	mux.HandleFunc("PATCH /products/{id}/stock", authorize(stock, h.updateStock))
+	mux.HandleFunc("POST /products/{id}/restock", authorize(stock, h.restock))
+	mux.HandleFunc("GET /products/{id}/stock/history", authorize(read, h.stockHistory))
 }
 
 func (h *ProductHandler) listProducts(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseProductFilter(r, h.productService.BaseCurrency())
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.listFiltered(w, r, filter)
+}
+
+// listFiltered responds with the products matching filter, by page or by
+// cursor.
+func (h *ProductHandler) listFiltered(w http.ResponseWriter, r *http.Request, filter models.ProductFilter) {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	products, err := h.productService.GetAll(ctx, page, limit)
+	if r.URL.Query().Has("cursor") {
+		result, err := h.productService.GetPage(ctx, filter, r.URL.Query().Get("cursor"), limit)
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+
+		h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":        result.Data,
+			"limit":       limit,
+			"next_cursor": nullableString(result.NextCursor),
+			"prev_cursor": nullableString(result.PrevCursor),
+		})
+		return
+	}
+
+	products, err := h.productService.GetAll(ctx, filter, page, limit)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch products")
+		respondWithServiceError(w, err)
 		return
 	}
 
-	total, _ := h.productService.Count(ctx)
+	total, _ := h.productService.Count(ctx, filter)
 
 	response := map[string]interface{}{
 		"data":  products,
@@ -56,22 +102,57 @@ func (h *ProductHandler) listProducts(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, response)
 }
 
+// exportProducts streams every product as NDJSON or CSV. ?updated_since limits
+// the export to products changed since an earlier run.
+func (h *ProductHandler) exportProducts(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := exportFilter(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out := newExportWriter(w, format, "products", productCSVHeader)
+	err = h.productService.Export(r.Context(), filter, func(p models.Product) error {
+		return out.write(p, func() []string { return productCSVRecord(p) })
+	})
+	if err == nil {
+		err = out.finish()
+	}
+	if err != nil && !out.started {
+		respondWithServiceError(w, err)
+		return
+	}
+	if err != nil {
+		// The status line is gone; drop the connection so the client sees a
+		// failed download rather than a silently truncated file.
+		log.Printf("Product export aborted after %d rows: %v", out.rows, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
 func (h *ProductHandler) createProduct(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateProductRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
 
 	product, err := h.productService.Create(ctx, &req)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
+	setETag(w, product.Version)
 	h.respondWithJSON(w, http.StatusCreated, product)
 }
 
@@ -85,16 +166,40 @@ func (h *ProductHandler) getProduct(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	product, err := h.productService.GetByID(ctx, id)
+	var currency string
+	if v := r.URL.Query().Get("currency"); v != "" {
+		if currency, err = models.NormalizeCurrency(v); err != nil {
+			h.respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	get := h.productService.GetByID
+	if includeDeleted(r) {
+		get = h.productService.GetByIDWithDeleted
+	}
+
+	product, err := get(ctx, id)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Database error")
+		respondWithServiceError(w, err)
 		return
 	}
 	if product == nil {
-		h.respondWithError(w, http.StatusNotFound, "Product not found")
+		respondWithServiceError(w, service.ErrProductNotFound)
 		return
 	}
 
+	// ?currency= reports the price in that currency instead of the base one.
+	if currency != "" {
+		price, ok := product.PriceIn(currency)
+		if !ok {
+			respondWithServiceError(w, fmt.Errorf("%w: product %d has no price in %s", service.ErrPriceUnavailable, id, currency))
+			return
+		}
+		product.Price = price
+	}
+
+	setETag(w, product.Version)
 	h.respondWithJSON(w, http.StatusOK, product)
 }
 
@@ -105,53 +210,209 @@ func (h *ProductHandler) updateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
 	var req models.UpdateProductRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	product, err := h.productService.Update(ctx, id, &req, version)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	setETag(w, product.Version)
+	h.respondWithJSON(w, http.StatusOK, product)
+}
+
+func (h *ProductHandler) patchProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
+	if !isMergePatch(r) {
+		w.Header().Set("Accept-Patch", mergePatchContentType)
+		h.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchContentType)
+		return
+	}
+
+	var patch models.ProductPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	product, err := h.productService.Patch(ctx, id, &patch, version)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	setETag(w, product.Version)
+	h.respondWithJSON(w, http.StatusOK, product)
+}
+
+// setPrice adds or replaces the price of a product in a currency other than
+// the base one.
+func (h *ProductHandler) setPrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
+	var price models.Money
+	if err := decodeJSON(w, r, &price); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	product, err := h.productService.SetPrice(ctx, id, price, version)
+	h.respondWithPriceList(w, product, err)
+}
+
+func (h *ProductHandler) deletePrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	currency, err := models.NormalizeCurrency(r.PathValue("currency"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	product, err := h.productService.Update(ctx, id, &req)
-	if err == sql.ErrNoRows {
-		h.respondWithError(w, http.StatusNotFound, "Product not found")
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
 		return
 	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	product, err := h.productService.DeletePrice(ctx, id, currency, version)
+	h.respondWithPriceList(w, product, err)
+}
+
+// respondWithPriceList reports the outcome of a price list change.
+func (h *ProductHandler) respondWithPriceList(w http.ResponseWriter, product *models.Product, err error) {
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
+	setETag(w, product.Version)
 	h.respondWithJSON(w, http.StatusOK, product)
 }
 
 func (h *ProductHandler) updateStock(w http.ResponseWriter, r *http.Request) {
+	h.changeStock(w, r, h.productService.UpdateStock, "Stock updated")
+}
+
+func (h *ProductHandler) restock(w http.ResponseWriter, r *http.Request) {
+	h.changeStock(w, r, h.productService.Restock, "Stock replenished")
+}
+
+func (h *ProductHandler) changeStock(
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(ctx context.Context, id, quantity, version int) error,
+	message string,
+) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	var req struct {
-		Quantity int `json:"quantity"`
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+
+	var req models.StockQuantityRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	if err := fn(ctx, id, req.Quantity, version); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": message})
+}
 
-	err = h.productService.UpdateStock(ctx, id, req.Quantity)
+func (h *ProductHandler) stockHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	h.respondWithJSON(w, http.StatusOK, map[string]string{"message": "Stock updated"})
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	movements, total, err := h.productService.GetStockHistory(ctx, id, page, limit)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"data":  movements,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	}
+
+	h.respondWithJSON(w, http.StatusOK, response)
 }
 
 func (h *ProductHandler) deleteProduct(w http.ResponseWriter, r *http.Request) {
@@ -161,20 +422,89 @@ func (h *ProductHandler) deleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	err = h.productService.Delete(ctx, id)
-	if err == sql.ErrNoRows {
-		h.respondWithError(w, http.StatusNotFound, "Product not found")
+	err = h.productService.Delete(ctx, id, version)
+	if err != nil {
+		respondWithServiceError(w, err)
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ProductHandler) restoreProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	product, err := h.productService.Restore(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	setETag(w, product.Version)
+	h.respondWithJSON(w, http.StatusOK, product)
+}
+
+// parseProductFilter reads the filter from the query string. Price bounds are
+// decimal amounts in the base currency.
+func parseProductFilter(r *http.Request, baseCurrency string) (models.ProductFilter, error) {
+	query := r.URL.Query()
+	filter := models.ProductFilter{
+		Query:          query.Get("q"),
+		Sort:           query.Get("sort"),
+		Order:          query.Get("order"),
+		IncludeDeleted: includeDeleted(r),
+	}
+
+	if v := query.Get("min_price"); v != "" {
+		price, err := models.ParseMoney(v, baseCurrency)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_price %q", v)
+		}
+		filter.MinPrice = &price.Amount
+	}
+	if v := query.Get("max_price"); v != "" {
+		price, err := models.ParseMoney(v, baseCurrency)
+		if err != nil {
+			return filter, fmt.Errorf("invalid max_price %q", v)
+		}
+		filter.MaxPrice = &price.Amount
+	}
+	if v := query.Get("in_stock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid in_stock %q", v)
+		}
+		filter.InStock = &inStock
+	}
+	if v := query.Get("category"); v != "" {
+		category, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid category %q", v)
+		}
+		filter.Category = &category
+	}
+
+	filter.Normalize()
+	if err := filter.Validate(); err != nil {
+		return filter, err
+	}
+	return filter, nil
 }
 
 func (h *ProductHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -184,9 +514,5 @@ func (h *ProductHandler) respondWithJSON(w http.ResponseWriter, code int, payloa
 }
 
 func (h *ProductHandler) respondWithError(w http.ResponseWriter, code int, message string) {
-	h.respondWithJSON(w, code, models.ErrorResponse{
-		Error:   http.StatusText(code),
-		Message: message,
-		Status:  code,
-	})
+	respondWithProblem(w, code, message)
 }
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+const (
+	// importTimeout bounds an import from reading the file to writing the
+	// report, in place of the server's shorter read and write timeouts.
+	importTimeout = 60 * time.Second
+
+	maxImportBytes = 32 << 20
+	maxImportRows  = 50000
+	maxImportLine  = 1 << 20
+)
+
+var errTooManyRows = fmt.Errorf("import is limited to %d rows", maxImportRows)
+
+// importProducts loads a CSV (with a header row) or NDJSON file of products.
+// ?mode=upsert updates products matched by name instead of creating
+// duplicates; ?dry_run=true only reports what would happen.
+func (h *ProductHandler) importProducts(w http.ResponseWriter, r *http.Request) {
+	opts := models.ProductImportOptions{Mode: r.URL.Query().Get("mode")}
+	if opts.Mode == "" {
+		opts.Mode = models.ImportModeInsert
+	}
+	opts.DryRun, _ = strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if err := opts.Validate(); err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Large catalogues take longer to upload and load than a single-row
+	// request, so the connection deadlines are extended like export does.
+	deadline := time.Now().Add(importTimeout)
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(deadline)
+	rc.SetWriteDeadline(deadline.Add(5 * time.Second))
+
+	var parse func(io.Reader) ([]models.ProductImportRow, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		parse = func(body io.Reader) ([]models.ProductImportRow, error) {
+			return parseCSVImport(body, h.productService.BaseCurrency())
+		}
+	case "application/x-ndjson", "application/ndjson":
+		parse = parseNDJSONImport
+	default:
+		h.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be text/csv or application/x-ndjson")
+		return
+	}
+
+	rows, err := parse(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import file exceeds %d bytes", maxImportBytes))
+		return
+	}
+	if err == errTooManyRows {
+		h.respondWithError(w, http.StatusRequestEntityTooLarge, "Import is limited to "+strconv.Itoa(maxImportRows)+" rows")
+		return
+	}
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) == 0 {
+		h.respondWithError(w, http.StatusBadRequest, "Import file has no rows")
+		return
+	}
+
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	report, err := h.productService.Import(ctx, rows, opts)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, report)
+}
+
+// parseCSVImport reads a CSV file whose header names the columns: name,
+// description, price, currency and stock, in any order. Empty cells leave the
+// field out; prices without a currency are in baseCurrency.
+func parseCSVImport(body io.Reader, baseCurrency string) ([]models.ProductImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		switch column {
+		case "name", "description", "price", "currency", "stock":
+		default:
+			return nil, fmt.Errorf("unknown CSV column %q", column)
+		}
+		if _, ok := columns[column]; ok {
+			return nil, fmt.Errorf("duplicate CSV column %q", column)
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV header must include a name column")
+	}
+
+	var rows []models.ProductImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, errTooManyRows
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := models.ProductImportRow{Line: line}
+		if len(record) != len(header) {
+			row.Error = fmt.Sprintf("expected %d fields, got %d", len(header), len(record))
+			rows = append(rows, row)
+			continue
+		}
+
+		currency := baseCurrency
+		if i, ok := columns["currency"]; ok && strings.TrimSpace(record[i]) != "" {
+			currency = strings.TrimSpace(record[i])
+		}
+
+		for column, i := range columns {
+			value := strings.TrimSpace(record[i])
+			if value == "" {
+				continue
+			}
+			switch column {
+			case "name":
+				row.Fields.Name = models.Optional[string]{Set: true, Value: value}
+			case "description":
+				row.Fields.Description = models.Optional[string]{Set: true, Value: value}
+			case "price":
+				price, err := models.ParseMoney(value, currency)
+				if err != nil {
+					row.Error = err.Error()
+				}
+				row.Fields.Price = models.Optional[models.Money]{Set: true, Value: price}
+			case "stock":
+				stock, err := strconv.Atoi(value)
+				if err != nil {
+					row.Error = fmt.Sprintf("invalid stock %q", value)
+				}
+				row.Fields.Stock = models.Optional[int]{Set: true, Value: stock}
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvError distinguishes malformed CSV from errors reading the request body.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("invalid CSV: %v", err)
+	}
+	return err
+}
+
+// parseNDJSONImport reads one product object per line, with the fields of a
+// merge patch. Blank lines are skipped.
+func parseNDJSONImport(body io.Reader) ([]models.ProductImportRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+
+	var rows []models.ProductImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, errTooManyRows
+		}
+
+		row := models.ProductImportRow{Line: line}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.Fields); err != nil {
+			row = models.ProductImportRow{Line: line, Error: "invalid JSON: " + err.Error()}
+		} else if decoder.More() {
+			row = models.ProductImportRow{Line: line, Error: "invalid JSON: more than one value on the line"}
+		}
+		row.Fields.Name.Value = strings.TrimSpace(row.Fields.Name.Value)
+		rows = append(rows, row)
+	}
+
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, fmt.Errorf("NDJSON lines are limited to %d bytes", maxImportLine)
+	}
+	return rows, scanner.Err()
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/ratelimit"
+	"go_microservices/internal/service"
+)
+
+// Route groups that rate limit rules refer to.
+const (
+	routeGroupAuth   = "auth"
+	routeGroupExport = "export"
+	routeGroupRead   = "read"
+	routeGroupWrite  = "write"
+
+	// routeGroupAuthFailure counts rejected credentials rather than routes;
+	// see LimitAuthFailures.
+	routeGroupAuthFailure = "auth_failure"
+)
+
+// RateLimit limits requests per route group and caller, using the rule for
+// the kind of caller: an API key, a user or, for anonymous callers, an IP
+// address. It must run after Authenticate, which identifies the caller. If
+// the limiter fails, requests are let through rather than rejected.
+//
+// trustedProxies is the number of proxies in front of the service that append
+// to X-Forwarded-For; see clientIP.
+func RateLimit(limiter ratelimit.Limiter, rules ratelimit.Rules, trustedProxies int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := routeGroup(r)
+		if group == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		kind, id := rateLimitIdentity(r, trustedProxies)
+		limit, ok := rules.Lookup(group, kind)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+		result, err := limiter.Allow(ctx, group+":"+kind+":"+id, limit)
+		cancel()
+		if err != nil {
+			log.Printf("Rate limiter failed, allowing request: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		setRateLimitHeaders(w, limit, result)
+		if !result.Allowed {
+			respondRateLimited(w, result)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type authFailureKey struct{}
+
+// LimitAuthFailures limits how often an IP address may present credentials
+// that Authenticate rejects, under the auth_failure:ip rule. Once an address
+// reaches the limit, its requests with an Authorization header are refused
+// before the credentials are checked, so invalid tokens and guessed API keys
+// cannot be tried faster than the rule allows. It must wrap Authenticate.
+func LimitAuthFailures(limiter ratelimit.Limiter, rules ratelimit.Rules, trustedProxies int, next http.Handler) http.Handler {
+	limit, ok := rules.Lookup(routeGroupAuthFailure, ratelimit.IdentityIP)
+	if !ok {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := routeGroupAuthFailure + ":" + ratelimit.IdentityIP + ":" + clientIP(r, trustedProxies)
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+		result, err := limiter.Check(ctx, key, limit)
+		cancel()
+		if err != nil {
+			log.Printf("Rate limiter failed, allowing request: %v", err)
+		} else if !result.Allowed {
+			setRateLimitHeaders(w, limit, result)
+			respondRateLimited(w, result)
+			return
+		}
+
+		failed := false
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authFailureKey{}, &failed)))
+		if !failed {
+			return
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := limiter.Allow(ctx, key, limit); err != nil {
+			log.Printf("Rate limiter failed to count an authentication failure: %v", err)
+		}
+	})
+}
+
+// reportAuthFailure tells LimitAuthFailures, if it wraps the request, that
+// the credentials of the request were rejected.
+func reportAuthFailure(r *http.Request) {
+	if failed, ok := r.Context().Value(authFailureKey{}).(*bool); ok {
+		*failed = true
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, limit ratelimit.Limit, result ratelimit.Result) {
+	w.Header().Set("RateLimit-Policy", limit.Policy())
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", resetSeconds(result))
+}
+
+func respondRateLimited(w http.ResponseWriter, result ratelimit.Result) {
+	reset := resetSeconds(result)
+	w.Header().Set("Retry-After", reset)
+	writeProblem(w, models.Problem{
+		Status: http.StatusTooManyRequests,
+		Code:   "rate_limited",
+		Detail: "rate limit exceeded, retry in " + reset + "s",
+	})
+}
+
+func resetSeconds(result ratelimit.Result) string {
+	return strconv.Itoa(int(math.Ceil(result.Reset.Seconds())))
+}
+
+// routeGroup returns the group of routes a request belongs to, or "" for
+// routes that are never limited.
+func routeGroup(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/" || r.URL.Path == "/health":
+		return ""
+	case strings.HasPrefix(r.URL.Path, "/auth/"):
+		return routeGroupAuth
+	case strings.HasSuffix(r.URL.Path, "/export"):
+		return routeGroupExport
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return routeGroupRead
+	default:
+		return routeGroupWrite
+	}
+}
+
+// rateLimitIdentity returns the kind and ID of the caller a request counts
+// against.
+func rateLimitIdentity(r *http.Request, trustedProxies int) (kind, id string) {
+	if principal := service.PrincipalFromContext(r.Context()); principal != nil {
+		if principal.IsAPIKey() {
+			return ratelimit.IdentityAPIKey, strconv.Itoa(principal.APIKeyID)
+		}
+		return ratelimit.IdentityUser, strconv.Itoa(principal.UserID)
+	}
+	return ratelimit.IdentityIP, clientIP(r, trustedProxies)
+}
+
+// clientIP returns the address of the client behind trustedProxies proxies.
+// Each proxy appends the address it received the request from to
+// X-Forwarded-For, so the client is the entry trustedProxies from the right;
+// anything left of it was sent by the client and may be forged. Without
+// trusted proxies, or if the header is shorter than the chain of proxies, the
+// peer address of the connection is used.
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var hops []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(header, ",")...)
+		}
+		if len(hops) >= trustedProxies {
+			if ip := net.ParseIP(strings.TrimSpace(hops[len(hops)-trustedProxies])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/ratelimit"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		forwarded      []string
+		trustedProxies int
+		want           string
+	}{
+		{"no proxies ignores the header", []string{"203.0.113.7"}, 0, "192.0.2.1"},
+		{"one proxy", []string{"203.0.113.7"}, 1, "203.0.113.7"},
+		{"one proxy with a forged entry", []string{"10.0.0.1, 203.0.113.7"}, 1, "203.0.113.7"},
+		{"two proxies", []string{"10.0.0.1, 203.0.113.7, 198.51.100.2"}, 2, "203.0.113.7"},
+		{"entries across headers", []string{"10.0.0.1", "203.0.113.7, 198.51.100.2"}, 2, "203.0.113.7"},
+		{"header shorter than the chain", []string{"203.0.113.7"}, 2, "192.0.2.1"},
+		{"not an address", []string{"unknown"}, 1, "192.0.2.1"},
+		{"no header", nil, 1, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/products", nil)
+			r.RemoteAddr = "192.0.2.1:41234"
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := clientIP(r, tt.trustedProxies); got != tt.want {
+				t.Fatalf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitAuthFailures(t *testing.T) {
+	tokens := auth.NewTokenIssuer([]byte("0123456789abcdef0123456789abcdef"), time.Minute)
+	valid, err := tokens.Issue(1, "ann@example.com", "customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := ratelimit.ParseRules("auth_failure:ip=2/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := LimitAuthFailures(ratelimit.NewMemoryLimiter(), rules, 0, Authenticate(tokens, nil, ok))
+
+	serve := func(authorization string) int {
+		r := httptest.NewRequest("GET", "/products", nil)
+		r.RemoteAddr = "192.0.2.1:41234"
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve("Bearer " + valid); code != http.StatusOK {
+		t.Fatalf("valid token: got %d", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code := serve("Bearer forged"); code != http.StatusUnauthorized {
+			t.Fatalf("invalid token %d: got %d", i+1, code)
+		}
+	}
+	if code := serve("Bearer forged"); code != http.StatusTooManyRequests {
+		t.Fatalf("invalid token over the limit: got %d", code)
+	}
+	if code := serve("Bearer " + valid); code != http.StatusTooManyRequests {
+		t.Fatalf("valid token from a blocked address: got %d", code)
+	}
+	if code := serve(""); code != http.StatusOK {
+		t.Fatalf("anonymous request from a blocked address: got %d", code)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/validate"
+)
+
+// maxBodyBytes limits JSON request bodies. Imports have their own limit.
+const maxBodyBytes = 1 << 20
+
+var errTrailingData = errors.New("request body must contain a single JSON value")
+
+// decodeJSON strictly decodes a JSON request body into dst and validates it
+// against its binding tags. The body must hold exactly one JSON value with no
+// fields unknown to dst. Errors are meant for respondWithDecodeError.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return err
+		}
+		return errTrailingData
+	}
+
+	return validate.Struct(dst)
+}
+
+// respondWithDecodeError reports an error returned by decodeJSON: 422 with the
+// invalid fields if validation failed, 413 if the body is too large and 400
+// otherwise.
+func respondWithDecodeError(w http.ResponseWriter, err error) {
+	problem := models.Problem{Status: http.StatusBadRequest, Code: "invalid_json", Detail: "Invalid request body"}
+
+	var (
+		invalid   validate.Errors
+		tooLarge  *http.MaxBytesError
+		typeError *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		problem.Status = http.StatusUnprocessableEntity
+		problem.Code = "validation_failed"
+		problem.Detail = "Validation failed"
+		problem.Errors = invalid
+	case errors.As(err, &tooLarge):
+		problem.Status = http.StatusRequestEntityTooLarge
+		problem.Code = "body_too_large"
+		problem.Detail = fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit)
+	case errors.Is(err, models.ErrInvalidMoney):
+		problem.Code = "invalid_money"
+		problem.Detail = err.Error()
+	case errors.Is(err, errTrailingData):
+		problem.Detail = err.Error()
+	case errors.As(err, &typeError) && typeError.Field != "":
+		problem.Detail = fmt.Sprintf("Field %q must be %s, not %s", typeError.Field, typeError.Type, typeError.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no exported type for this error.
+		problem.Code = "unknown_field"
+		problem.Detail = "Unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	case errors.Is(err, io.EOF):
+		problem.Code = "empty_body"
+		problem.Detail = "Request body is empty"
+	}
+
+	writeProblem(w, problem)
+}
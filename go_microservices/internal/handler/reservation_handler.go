@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+type ReservationHandler struct {
+	reservationService *service.ReservationService
+}
+
+func NewReservationHandler(reservationService *service.ReservationService) *ReservationHandler {
+	return &ReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+// RegisterRoutes registers the reservation routes, each with the policy that
+// decides who may call it. Reservations hold and change stock, so they are
+// for staff and API keys with stock:write.
+func (h *ReservationHandler) RegisterRoutes(mux Router) {
+	stock := hasRole(models.RoleStaff, models.ScopeStockWrite)
+	read := hasRole(models.RoleStaff, models.ScopeStockWrite, models.ScopeProductsRead)
+
+	mux.HandleFunc("POST /products/{id}/reservations", authorize(stock, h.reserve))
+	mux.HandleFunc("GET /reservations/{id}", authorize(read, h.getReservation))
+	mux.HandleFunc("POST /reservations/{id}/commit", authorize(stock, h.commit))
+	mux.HandleFunc("POST /reservations/{id}/release", authorize(stock, h.release))
+}
+
+func (h *ReservationHandler) reserve(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.CreateReservationRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	reservation, err := h.reservationService.Reserve(ctx, productID, &req)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusCreated, reservation)
+}
+
+func (h *ReservationHandler) getReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid reservation ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	reservation, err := h.reservationService.GetByID(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if reservation == nil {
+		respondWithServiceError(w, service.ErrReservationNotFound)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, reservation)
+}
+
+func (h *ReservationHandler) commit(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.reservationService.Commit)
+}
+
+func (h *ReservationHandler) release(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.reservationService.Release)
+}
+
+func (h *ReservationHandler) transition(
+	w http.ResponseWriter,
+	r *http.Request,
+	fn func(ctx context.Context, id int) (*models.Reservation, error),
+) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid reservation ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	ctx = service.WithActor(ctx, requestActor(r))
+
+	reservation, err := fn(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	h.respondWithJSON(w, http.StatusOK, reservation)
+}
+
+func (h *ReservationHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
+
+func (h *ReservationHandler) respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithProblem(w, code, message)
+}
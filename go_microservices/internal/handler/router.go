@@ -0,0 +1,9 @@
+package handler
+
+import "net/http"
+
+// Router is where handlers register their routes. *http.ServeMux is the one
+// the server uses; tests record the route table through it.
+type Router interface {
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
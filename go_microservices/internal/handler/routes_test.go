@@ -0,0 +1,142 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/cache"
+	"go_microservices/internal/handler"
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository/memory"
+	"go_microservices/internal/service"
+)
+
+// routeTable records the patterns handlers register while serving them from a
+// real mux.
+type routeTable struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (t *routeTable) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	t.patterns = append(t.patterns, pattern)
+	t.ServeMux.HandleFunc(pattern, h)
+}
+
+// newRouteTable registers every handler the server does over one in-memory
+// store.
+func newRouteTable(t *testing.T) *routeTable {
+	t.Helper()
+
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepository(store)
+	productRepo := memory.NewProductRepository(store)
+	movementRepo := memory.NewStockMovementRepository(store)
+	c := cache.NewMemoryCache(100, cache.Options{TTL: time.Minute, NegativeTTL: time.Minute})
+	tokens := auth.NewTokenIssuer([]byte("0123456789abcdef0123456789abcdef"), time.Minute)
+
+	productService := service.NewProductService(productRepo, movementRepo, c, "USD")
+	routes := &routeTable{ServeMux: http.NewServeMux()}
+	handler.NewAuthHandler(service.NewAuthService(
+		userRepo, tokens, memory.NewRefreshTokenStore(), c, time.Hour,
+	)).RegisterRoutes(routes)
+	handler.NewAPIKeyHandler(service.NewAPIKeyService(memory.NewAPIKeyRepository(store), c)).RegisterRoutes(routes)
+	handler.NewUserHandler(service.NewUserService(userRepo, c)).RegisterRoutes(routes)
+	handler.NewProductHandler(productService).RegisterRoutes(routes)
+	handler.NewCategoryHandler(
+		service.NewCategoryService(memory.NewCategoryRepository(store), c), productService,
+	).RegisterRoutes(routes)
+	handler.NewOrderHandler(service.NewOrderService(
+		memory.NewOrderRepository(store), productRepo, userRepo, movementRepo, c,
+	)).RegisterRoutes(routes)
+	handler.NewReservationHandler(service.NewReservationService(
+		memory.NewReservationRepository(store), productRepo, movementRepo, c, time.Minute,
+	)).RegisterRoutes(routes)
+	return routes
+}
+
+// serve sends a request on behalf of principal, nil for an anonymous caller.
+func (t *routeTable) serve(method, path, body string, principal *service.Principal) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if principal != nil {
+		req = req.WithContext(service.WithPrincipal(req.Context(), principal))
+	}
+	rec := httptest.NewRecorder()
+	t.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestIncludeDeletedFollowsPolicy asks every GET route for soft-deleted
+// records. Only admins may see them, so a route that reads include_deleted
+// without a policy for it fails here as soon as it is registered.
+func TestIncludeDeletedFollowsPolicy(t *testing.T) {
+	routes := newRouteTable(t)
+	admin := &service.Principal{UserID: 9, Role: models.RoleAdmin}
+
+	for _, step := range []struct{ method, path, body string }{
+		{"POST", "/users", `{"name":"Ann","email":"ann@example.com"}`},
+		{"DELETE", "/users/1", ""},
+		{"POST", "/categories", `{"name":"Tools"}`},
+		{"POST", "/products", `{"name":"Widget","price":{"amount":"2.50","currency":"USD"},"stock":5}`},
+		{"PUT", "/products/1/categories", `{"category_ids":[1]}`},
+		{"DELETE", "/products/1", ""},
+	} {
+		if rec := routes.serve(step.method, step.path, step.body, admin); rec.Code >= 300 {
+			t.Fatalf("%s %s: got %d: %s", step.method, step.path, rec.Code, rec.Body)
+		}
+	}
+
+	callers := map[string]*service.Principal{
+		"anonymous": nil,
+		"customer":  {UserID: 1, Role: models.RoleCustomer},
+		"staff":     {UserID: 2, Role: models.RoleStaff},
+		"api key": {APIKeyID: 1, Scopes: []string{
+			models.ScopeProductsRead, models.ScopeProductsWrite, models.ScopeStockWrite,
+			models.ScopeUsersRead, models.ScopeUsersWrite,
+		}},
+		"admin": admin,
+	}
+	pathValues := strings.NewReplacer("{id}", "1", "{currency}", "USD")
+
+	var shownToAdmin []string
+	for _, pattern := range routes.patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+		if method != http.MethodGet {
+			continue
+		}
+		path = pathValues.Replace(path) + "?include_deleted=true"
+
+		for name, principal := range callers {
+			rec := routes.serve(method, path, "", principal)
+			leaked := rec.Code < 300 && strings.Contains(rec.Body.String(), `"deleted_at":"`)
+			switch {
+			case principal == admin && leaked:
+				shownToAdmin = append(shownToAdmin, pattern)
+			case leaked:
+				t.Errorf("%s: %s sees deleted records", pattern, name)
+			}
+		}
+	}
+
+	sort.Strings(shownToAdmin)
+	want := []string{
+		"GET /categories/{id}/products",
+		"GET /products",
+		"GET /products/export",
+		"GET /products/{id}",
+		"GET /users",
+		"GET /users/export",
+		"GET /users/{id}",
+	}
+	if strings.Join(shownToAdmin, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("routes that show deleted records to admins: got %v, want %v", shownToAdmin, want)
+	}
+}
@@ -0,0 +1,13 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// includeDeleted reports whether the request asks for soft-deleted rows as
+// well (?include_deleted=true).
+func includeDeleted(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("include_deleted"))
+	return v
+}
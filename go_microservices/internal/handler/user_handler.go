@@ -2,8 +2,8 @@ package handler
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,12 +22,21 @@ func NewUserHandler(userService *service.UserService) *UserHandler {
 	}
 }
 
-func (h *UserHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /users", h.listUsers)
-	mux.HandleFunc("POST /users", h.createUser)
-	mux.HandleFunc("GET /users/{id}", h.getUser)
-	mux.HandleFunc("PUT /users/{id}", h.updateUser)
-	mux.HandleFunc("DELETE /users/{id}", h.deleteUser)
+// RegisterRoutes registers the user routes, each with the policy that decides
+// who may call it. Customers may only see and change their own account.
+func (h *UserHandler) RegisterRoutes(mux Router) {
+	admin := hasRole(models.RoleAdmin)
+	staffRead := deletedRequires(admin, hasRole(models.RoleStaff, models.ScopeUsersRead))
+
+	mux.HandleFunc("GET /users", authorize(staffRead, h.listUsers))
+	mux.HandleFunc("GET /users/export", authorize(staffRead, h.exportUsers))
+	mux.HandleFunc("POST /users", authorize(hasRole(models.RoleAdmin, models.ScopeUsersWrite), h.createUser))
+	mux.HandleFunc("GET /users/{id}", authorize(deletedRequires(admin, selfOrRole(models.RoleStaff, models.ScopeUsersRead)), h.getUser))
+	mux.HandleFunc("PUT /users/{id}", authorize(selfOrRole(models.RoleAdmin, models.ScopeUsersWrite), h.updateUser))
+	mux.HandleFunc("PATCH /users/{id}", authorize(selfOrRole(models.RoleAdmin, models.ScopeUsersWrite), h.patchUser))
+	mux.HandleFunc("DELETE /users/{id}", authorize(selfOrRole(models.RoleAdmin), h.deleteUser))
+	mux.HandleFunc("POST /users/{id}/restore", authorize(admin, h.restoreUser))
+	mux.HandleFunc("PUT /users/{id}/role", authorize(admin, h.setRole))
 }
 
 func (h *UserHandler) listUsers(w http.ResponseWriter, r *http.Request) {
@@ -37,13 +46,29 @@ func (h *UserHandler) listUsers(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	users, err := h.userService.GetAll(ctx, page, limit)
+	if r.URL.Query().Has("cursor") {
+		result, err := h.userService.GetPage(ctx, r.URL.Query().Get("cursor"), limit, includeDeleted(r))
+		if err != nil {
+			respondWithServiceError(w, err)
+			return
+		}
+
+		h.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"data":        result.Data,
+			"limit":       limit,
+			"next_cursor": nullableString(result.NextCursor),
+			"prev_cursor": nullableString(result.PrevCursor),
+		})
+		return
+	}
+
+	users, err := h.userService.GetAll(ctx, page, limit, includeDeleted(r))
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Failed to fetch users")
+		respondWithServiceError(w, err)
 		return
 	}
 
-	total, _ := h.userService.Count(ctx)
+	total, _ := h.userService.Count(ctx, includeDeleted(r))
 
 	response := map[string]interface{}{
 		"data":  users,
@@ -55,10 +80,43 @@ func (h *UserHandler) listUsers(w http.ResponseWriter, r *http.Request) {
 	h.respondWithJSON(w, http.StatusOK, response)
 }
 
+// exportUsers streams every user as NDJSON or CSV. ?updated_since limits
+// the export to users changed since an earlier run.
+func (h *UserHandler) exportUsers(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter, err := exportFilter(r)
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out := newExportWriter(w, format, "users", userCSVHeader)
+	err = h.userService.Export(r.Context(), filter, func(u models.User) error {
+		return out.write(u, func() []string { return userCSVRecord(u) })
+	})
+	if err == nil {
+		err = out.finish()
+	}
+	if err != nil && !out.started {
+		respondWithServiceError(w, err)
+		return
+	}
+	if err != nil {
+		// The status line is gone; drop the connection so the client sees a
+		// failed download rather than a silently truncated file.
+		log.Printf("User export aborted after %d rows: %v", out.rows, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
 func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
@@ -67,14 +125,11 @@ func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.userService.Create(ctx, &req)
 	if err != nil {
-		if err.Error() == "user with email already exists" {
-			h.respondWithError(w, http.StatusConflict, err.Error())
-			return
-		}
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
+	setETag(w, user.Version)
 	h.respondWithJSON(w, http.StatusCreated, user)
 }
 
@@ -88,16 +143,22 @@ func (h *UserHandler) getUser(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	user, err := h.userService.GetByID(ctx, id)
+	get := h.userService.GetByID
+	if includeDeleted(r) {
+		get = h.userService.GetByIDWithDeleted
+	}
+
+	user, err := get(ctx, id)
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, "Database error")
+		respondWithServiceError(w, err)
 		return
 	}
 	if user == nil {
-		h.respondWithError(w, http.StatusNotFound, "User not found")
+		respondWithServiceError(w, service.ErrUserNotFound)
 		return
 	}
 
+	setETag(w, user.Version)
 	h.respondWithJSON(w, http.StatusOK, user)
 }
 
@@ -108,25 +169,98 @@ func (h *UserHandler) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
 	var req models.UpdateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userService.Update(ctx, id, &req, version)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
+	h.respondWithJSON(w, http.StatusOK, user)
+}
+
+func (h *UserHandler) patchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		h.respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
+	if !isMergePatch(r) {
+		w.Header().Set("Accept-Patch", mergePatchContentType)
+		h.respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchContentType)
+		return
+	}
+
+	var patch models.UserPatch
+	if err := decodeJSON(w, r, &patch); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	user, err := h.userService.Update(ctx, id, &req)
-	if err == sql.ErrNoRows {
-		h.respondWithError(w, http.StatusNotFound, "User not found")
+	user, err := h.userService.Patch(ctx, id, &patch, version)
+	if err != nil {
+		respondWithServiceError(w, err)
 		return
 	}
+
+	setETag(w, user.Version)
+	h.respondWithJSON(w, http.StatusOK, user)
+}
+
+func (h *UserHandler) setRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		h.respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
+	var req models.SetRoleRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		respondWithDecodeError(w, err)
 		return
 	}
 
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userService.SetRole(ctx, id, req.Role, version)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
 	h.respondWithJSON(w, http.StatusOK, user)
 }
 
@@ -137,20 +271,42 @@ func (h *UserHandler) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		h.respondWithError(w, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	err = h.userService.Delete(ctx, id)
-	if err == sql.ErrNoRows {
-		h.respondWithError(w, http.StatusNotFound, "User not found")
+	err = h.userService.Delete(ctx, id, version)
+	if err != nil {
+		respondWithServiceError(w, err)
 		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) restoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		h.respondWithError(w, http.StatusInternalServerError, err.Error())
+		h.respondWithError(w, http.StatusBadRequest, "Invalid user ID")
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.userService.Restore(ctx, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+
+	setETag(w, user.Version)
+	h.respondWithJSON(w, http.StatusOK, user)
 }
 
 func (h *UserHandler) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -160,9 +316,5 @@ func (h *UserHandler) respondWithJSON(w http.ResponseWriter, code int, payload i
 }
 
 func (h *UserHandler) respondWithError(w http.ResponseWriter, code int, message string) {
-	h.respondWithJSON(w, code, models.ErrorResponse{
-		Error:   http.StatusText(code),
-		Message: message,
-		Status:  code,
-	})
+	respondWithProblem(w, code, message)
 }
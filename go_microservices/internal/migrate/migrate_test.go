@@ -0,0 +1,495 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeDB is the part of PostgreSQL the migrator talks to: the advisory lock,
+// schema_migrations, the check for an existing users table and transactions.
+// Any other statement is a migration script, which is logged and succeeds
+// unless it contains "FAIL".
+type fakeDB struct {
+	lock chan struct{}
+
+	mu         sync.Mutex
+	hasTable   bool
+	hasUsers   bool
+	applied    map[int64]time.Time
+	log        []string
+	lockWaiter chan struct{}
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{
+		lock:    make(chan struct{}, 1),
+		applied: make(map[int64]time.Time),
+	}
+}
+
+func (db *fakeDB) open(t *testing.T) *sql.DB {
+	sqlDB := sql.OpenDB(db)
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
+
+func (db *fakeDB) record(entry string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.log = append(db.log, entry)
+}
+
+func (db *fakeDB) takeLog() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	log := db.log
+	db.log = nil
+	return log
+}
+
+func (db *fakeDB) versions() []int64 {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var versions []int64
+	for v := int64(1); v <= 10; v++ {
+		if _, ok := db.applied[v]; ok {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+func (db *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                            { return nil }
+
+type fakeConn struct {
+	db      *fakeDB
+	locked  bool
+	pending []func()
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver does not prepare statements")
+}
+
+func (c *fakeConn) Close() error {
+	if c.locked {
+		<-c.db.lock
+	}
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN")
+	c.pending = []func(){}
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.record("COMMIT")
+	c.db.mu.Lock()
+	for _, change := range c.pending {
+		change()
+	}
+	c.db.mu.Unlock()
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	if c.pending != nil {
+		c.db.record("ROLLBACK")
+	}
+	c.pending = nil
+	return nil
+}
+
+// change applies fn now, or at commit inside a transaction. It runs with
+// db.mu held.
+func (c *fakeConn) change(fn func()) {
+	if c.pending != nil {
+		c.pending = append(c.pending, fn)
+		return
+	}
+	c.db.mu.Lock()
+	fn()
+	c.db.mu.Unlock()
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query = strings.Join(strings.Fields(query), " ")
+	db := c.db
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_advisory_lock("):
+		if args[0].Value != int64(lockKey) {
+			return nil, fmt.Errorf("locked key %v", args[0].Value)
+		}
+		select {
+		case db.lock <- struct{}{}:
+		default:
+			db.mu.Lock()
+			waiter := db.lockWaiter
+			db.lockWaiter = nil
+			db.mu.Unlock()
+			if waiter != nil {
+				close(waiter)
+			}
+			select {
+			case db.lock <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		c.locked = true
+		db.record("LOCK")
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock("):
+		if c.locked {
+			c.locked = false
+			db.record("UNLOCK")
+			<-db.lock
+		}
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		db.record("CREATE schema_migrations")
+		c.change(func() { db.hasTable = true })
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		version := args[0].Value.(int64)
+		db.record(fmt.Sprintf("RECORD %d", version))
+		c.change(func() { db.applied[version] = time.Now() })
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		version := args[0].Value.(int64)
+		db.record(fmt.Sprintf("FORGET %d", version))
+		c.change(func() { delete(db.applied, version) })
+	default:
+		db.record(query)
+		if strings.Contains(query, "FAIL") {
+			return nil, errors.New("syntax error")
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch query {
+	case "SELECT to_regclass('public.users') IS NOT NULL":
+		return &fakeRows{columns: []string{"exists"}, rows: [][]driver.Value{{db.hasUsers}}}, nil
+	case "SELECT version, applied_at FROM schema_migrations":
+		if !db.hasTable {
+			return nil, errors.New(`relation "schema_migrations" does not exist`)
+		}
+		rows := &fakeRows{columns: []string{"version", "applied_at"}}
+		for version, at := range db.applied {
+			rows.rows = append(rows.rows, []driver.Value{version, at})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var testMigrations = fstest.MapFS{
+	"001_init.up.sql":     {Data: []byte("CREATE TABLE users")},
+	"001_init.down.sql":   {Data: []byte("DROP TABLE users")},
+	"002_orders.up.sql":   {Data: []byte("CREATE TABLE orders")},
+	"002_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+	"003_index.up.sql":    {Data: []byte("CREATE INDEX orders_user_id")},
+	"003_index.down.sql":  {Data: []byte("DROP INDEX orders_user_id")},
+	"migrations.go":       {Data: []byte("package migrations")},
+}
+
+func newMigrator(t *testing.T, db *fakeDB, fsys fstest.MapFS) *Migrator {
+	t.Helper()
+	m, err := New(db.open(t), fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func migrationVersions(migrations []Migration) []int64 {
+	var versions []int64
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE users", Down: "DROP TABLE users"},
+		{Version: 2, Name: "orders", Up: "CREATE TABLE orders", Down: "DROP TABLE orders"},
+		{Version: 3, Name: "index", Up: "CREATE INDEX orders_user_id", Down: "DROP INDEX orders_user_id"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Fatalf("got %+v, want %+v", migrations, want)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {
+			"001_init.up.sql": {Data: []byte("CREATE TABLE users")},
+		},
+		"conflicting names": {
+			"001_init.up.sql":    {Data: []byte("CREATE TABLE users")},
+			"001_users.down.sql": {Data: []byte("DROP TABLE users")},
+		},
+	} {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
+
+func TestUpAppliesPendingMigrationsUnderTheLock(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	m := newMigrator(t, db, testMigrations)
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := migrationVersions(applied); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("applied %v", got)
+	}
+	want := []string{
+		"LOCK", "CREATE schema_migrations",
+		"BEGIN", "CREATE TABLE users", "RECORD 1", "COMMIT",
+		"BEGIN", "CREATE TABLE orders", "RECORD 2", "COMMIT",
+		"BEGIN", "CREATE INDEX orders_user_id", "RECORD 3", "COMMIT",
+		"UNLOCK",
+	}
+	if got := db.takeLog(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %q, want %q", got, want)
+	}
+
+	// A second run finds nothing to do and holds the lock all the same.
+	db.hasUsers = true
+	applied, err = m.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("applied %v, %v on an up-to-date database", migrationVersions(applied), err)
+	}
+	if got, want := db.takeLog(), []string{"LOCK", "CREATE schema_migrations", "UNLOCK"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %q, want %q", got, want)
+	}
+}
+
+func TestUpWaitsForTheLock(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	m := newMigrator(t, db, testMigrations)
+
+	// Another replica holds the lock.
+	db.lock <- struct{}{}
+	waiting := make(chan struct{})
+	db.lockWaiter = waiting
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Up(ctx)
+		done <- err
+	}()
+	select {
+	case <-waiting:
+	case err := <-done:
+		t.Fatalf("Up returned %v without waiting for the lock", err)
+	}
+	if got := db.versions(); len(got) != 0 {
+		t.Fatalf("applied %v without the lock", got)
+	}
+
+	<-db.lock
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := db.versions(); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("applied %v", got)
+	}
+
+	// Replicas starting together apply each migration once.
+	db = newFakeDB()
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		m := newMigrator(t, db, testMigrations)
+		go func() {
+			_, err := m.Up(ctx)
+			errs <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := 0
+	for _, entry := range db.takeLog() {
+		if strings.HasPrefix(entry, "CREATE TABLE users") {
+			runs++
+		}
+	}
+	if runs != 1 {
+		t.Fatalf("ran the first migration %d times", runs)
+	}
+}
+
+func TestUpStopsAtAFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	fsys := fstest.MapFS{}
+	for name, file := range testMigrations {
+		fsys[name] = file
+	}
+	fsys["002_orders.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE orders FAIL")}
+	m := newMigrator(t, db, fsys)
+
+	applied, err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "migration 002_orders: syntax error") {
+		t.Fatalf("got %v, want the failure of 002_orders", err)
+	}
+	if got := migrationVersions(applied); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("reported %v as applied", got)
+	}
+	if got := db.versions(); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("recorded %v", got)
+	}
+	log := db.takeLog()
+	if got, want := log[len(log)-2:], []string{"ROLLBACK", "UNLOCK"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ended with %q, want %q", got, want)
+	}
+}
+
+func TestUpRefusesAnUnversionedSchemaUntilBaselined(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	db.hasUsers = true
+	m := newMigrator(t, db, testMigrations)
+
+	if _, err := m.Up(ctx); !errors.Is(err, ErrUnversionedSchema) {
+		t.Fatalf("got %v, want %v", err, ErrUnversionedSchema)
+	}
+	if got, want := db.takeLog(), []string{"LOCK", "CREATE schema_migrations", "UNLOCK"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %q, want %q", got, want)
+	}
+
+	if _, err := m.Baseline(ctx, 4); err == nil {
+		t.Fatal("baselined an unknown version")
+	}
+	recorded, err := m.Baseline(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := migrationVersions(recorded); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("recorded %v", got)
+	}
+	if got, want := db.takeLog(), []string{"LOCK", "CREATE schema_migrations", "RECORD 1", "RECORD 2", "UNLOCK"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %q, want %q", got, want)
+	}
+
+	// Baselining again records nothing new.
+	if recorded, err := m.Baseline(ctx, 2); err != nil || len(recorded) != 0 {
+		t.Fatalf("recorded %v, %v again", migrationVersions(recorded), err)
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := migrationVersions(applied); !reflect.DeepEqual(got, []int64{3}) {
+		t.Fatalf("applied %v after the baseline", got)
+	}
+}
+
+func TestDownRevertsTheLatestMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := newFakeDB()
+	m := newMigrator(t, db, testMigrations)
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	db.takeLog()
+
+	reverted, err := m.Down(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := migrationVersions(reverted); !reflect.DeepEqual(got, []int64{3, 2}) {
+		t.Fatalf("reverted %v", got)
+	}
+	want := []string{
+		"LOCK", "CREATE schema_migrations",
+		"BEGIN", "DROP INDEX orders_user_id", "FORGET 3", "COMMIT",
+		"BEGIN", "DROP TABLE orders", "FORGET 2", "COMMIT",
+		"UNLOCK",
+	}
+	if got := db.takeLog(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ran %q, want %q", got, want)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if applied := s.AppliedAt != nil; applied != (s.Version == 1) {
+			t.Errorf("status of %d: applied = %v", s.Version, applied)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	for name, file := range testMigrations {
+		if err := os.WriteFile(filepath.Join(dir, name), file.Data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up, down, err := Create(dir, " Add Stock ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up != filepath.Join(dir, "004_add_stock.up.sql") || down != filepath.Join(dir, "004_add_stock.down.sql") {
+		t.Fatalf("created %s and %s", up, down)
+	}
+	if migrations, err := Load(os.DirFS(dir)); err != nil || len(migrations) != 4 {
+		t.Fatalf("loaded %d migrations, %v", len(migrations), err)
+	}
+
+	if _, _, err := Create(dir, "drop-table"); err == nil {
+		t.Fatal("created a migration with an invalid name")
+	}
+}
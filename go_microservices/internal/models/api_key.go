@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+)
+
+// Scopes an API key can be granted. A key can only call the routes that
+// accept one of its scopes.
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeStockWrite    = "stock:write"
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+)
+
+var scopes = map[string]bool{
+	ScopeProductsRead:  true,
+	ScopeProductsWrite: true,
+	ScopeStockWrite:    true,
+	ScopeUsersRead:     true,
+	ScopeUsersWrite:    true,
+}
+
+func ValidScope(scope string) bool {
+	return scopes[scope]
+}
+
+// APIKey lets a service call the API without a user login. The key itself is
+// only shown once, on creation.
+type APIKey struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	CreatedBy  *int       `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Active reports whether the key may be used at the given time.
+func (k *APIKey) Active(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIKeyRequest creates a key. A key without expires_at never expires.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey is the response to creating a key, the only one that
+// contains the key.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
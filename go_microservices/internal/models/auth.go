@@ -0,0 +1,33 @@
+package models
+
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest carries a refresh token, to exchange it for new tokens or to
+// revoke it.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenPair is issued on login: a short-lived access token for the
+// Authorization header and a refresh token to get the next pair with.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RegisterResponse is the new user together with its first pair of tokens.
+type RegisterResponse struct {
+	User *User `json:"user"`
+	TokenPair
+}
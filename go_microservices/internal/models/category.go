@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+type Category struct {
+	ID        int       `json:"id" db:"id"`
+	ParentID  *int      `json:"parent_id" db:"parent_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CategoryNode is a category together with its subcategories.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// Find returns the node with the given ID in the subtree rooted at n, or nil.
+func (n *CategoryNode) Find(id int) *CategoryNode {
+	if n.ID == id {
+		return n
+	}
+	for _, child := range n.Children {
+		if found := child.Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// BuildCategoryTree arranges categories into trees under their parents. The
+// order of siblings follows the order of categories.
+func BuildCategoryTree(categories []Category) []*CategoryNode {
+	nodes := make(map[int]*CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &CategoryNode{Category: c, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, c := range categories {
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok {
+				parent.Children = append(parent.Children, nodes[c.ID])
+				continue
+			}
+		}
+		roots = append(roots, nodes[c.ID])
+	}
+	return roots
+}
+
+// CreateCategoryRequest creates a category; without parent_id it is a
+// top-level one.
+type CreateCategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// UpdateCategoryRequest renames or moves a category (PUT). A null parent_id
+// makes it a top-level category.
+type UpdateCategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// SetProductCategoriesRequest replaces the categories of a product.
+type SetProductCategoriesRequest struct {
+	CategoryIDs []int `json:"category_ids"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, c := range []Cursor{
+		{Sort: "id", Order: "asc", ID: 1},
+		{Sort: "name", Order: "desc", Value: "Ünïcode, \"quoted\" & spaced", ID: 42, Backward: true},
+		{Sort: "price", Order: "asc", Value: "-1250", ID: 7},
+	} {
+		encoded := c.Encode()
+		got, err := DecodeCursor(encoded)
+		if err != nil || *got != c {
+			t.Errorf("DecodeCursor(%q) = %+v, %v, want %+v", encoded, got, err, c)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsMalformedCursors(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	for name, cursor := range map[string]string{
+		"empty":             "",
+		"not base64":        "not a cursor!",
+		"padded base64":     base64.URLEncoding.EncodeToString([]byte(`{"s":"id","o":"asc","id":12}`)),
+		"standard alphabet": base64.RawStdEncoding.EncodeToString([]byte(`{"s":"id","o":"asc","v":"??>","id":1}`)),
+		"not JSON":          encode("id=1"),
+		"truncated JSON":    encode(`{"s":"id","o":"asc","id":1`),
+		"wrong type":        encode(`{"s":"id","o":"asc","id":"1"}`),
+		"missing id":        encode(`{"s":"id","o":"asc"}`),
+		"zero id":           encode(`{"s":"id","o":"asc","id":0}`),
+		"negative id":       encode(`{"s":"id","o":"asc","id":-5}`),
+		"JSON array":        encode(`[1]`),
+		"truncated cursor":  Cursor{Sort: "id", Order: "asc", ID: 12}.Encode()[:10],
+	} {
+		if got, err := DecodeCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: DecodeCursor(%q) = %+v, %v, want %v", name, cursor, got, err, ErrInvalidCursor)
+		}
+	}
+}
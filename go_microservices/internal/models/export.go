@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// ExportFilter narrows GET /users/export and GET /products/export. A nil
+// UpdatedSince exports every row.
+type ExportFilter struct {
+	UpdatedSince   *time.Time
+	IncludeDeleted bool
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidMoney = errors.New("invalid money")
+
+// currencyExponents lists the supported ISO 4217 currencies and the number of
+// minor-unit digits of each.
+var currencyExponents = map[string]int{
+	"AUD": 2, "BHD": 3, "BYN": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "INR": 2, "JPY": 0, "KRW": 0, "KWD": 3,
+	"KZT": 2, "NOK": 2, "PLN": 2, "RUB": 2, "SEK": 2, "TRY": 2, "UAH": 2,
+	"USD": 2,
+}
+
+// Money is an amount in the minor units of its currency (cents for USD), so
+// that prices add up without floating-point rounding. In JSON it is
+// {"amount":"999.99","currency":"USD"}.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// ValidCurrency reports whether code is a supported ISO 4217 currency.
+func ValidCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+// NormalizeCurrency upper-cases a currency code and checks it is supported.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !ValidCurrency(code) {
+		return "", fmt.Errorf("%w: unsupported currency %q", ErrInvalidMoney, code)
+	}
+	return code, nil
+}
+
+// ParseMoney parses a decimal amount such as "12.5" in the given currency. It
+// rejects more fractional digits than the currency has.
+func ParseMoney(amount, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	exponent := currencyExponents[currency]
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(whole) > 15 || len(frac) > exponent || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q is not a valid %s amount", ErrInvalidMoney, amount, currency)
+	}
+
+	minor, _ := strconv.ParseInt(whole+frac+strings.Repeat("0", exponent-len(frac)), 10, 64)
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal formats the amount in major units, e.g. "999.99".
+func (m Money) Decimal() string {
+	exponent := currencyExponents[m.Currency]
+
+	// The magnitude is taken as unsigned, since -math.MinInt64 does not fit
+	// in an int64.
+	amount := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	digits := strconv.FormatUint(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	amount, _ := json.Marshal(m.Decimal())
+	return json.Marshal(moneyJSON{Amount: amount, Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts the amount as a string or a number. Numbers are read
+// from their literal text, never through a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&v); err != nil {
+		return fmt.Errorf("%w: expected {\"amount\": ..., \"currency\": ...}", ErrInvalidMoney)
+	}
+	if v.Currency == "" {
+		return fmt.Errorf("%w: currency is required", ErrInvalidMoney)
+	}
+
+	amount := string(v.Amount)
+	if strings.HasPrefix(amount, `"`) {
+		if err := json.Unmarshal(v.Amount, &amount); err != nil {
+			return fmt.Errorf("%w: invalid amount", ErrInvalidMoney)
+		}
+	}
+	if amount == "" {
+		return fmt.Errorf("%w: amount is required", ErrInvalidMoney)
+	}
+
+	parsed, err := ParseMoney(amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             Money
+	}{
+		{"12.5", "USD", Money{1250, "USD"}},
+		{"12.50", "USD", Money{1250, "USD"}},
+		{"12", "USD", Money{1200, "USD"}},
+		{"0.01", "USD", Money{1, "USD"}},
+		{"007.10", "USD", Money{710, "USD"}},
+		{" 3.99 ", "usd", Money{399, "USD"}},
+		{"1000", "JPY", Money{1000, "JPY"}},
+		{"1.234", "BHD", Money{1234, "BHD"}},
+		{"-0.01", "EUR", Money{-1, "EUR"}},
+		{"-0", "EUR", Money{0, "EUR"}},
+		// The largest amounts: 15 whole digits and every fractional one.
+		{"999999999999999.99", "USD", Money{99999999999999999, "USD"}},
+		{"999999999999999.999", "KWD", Money{999999999999999999, "KWD"}},
+		{"-999999999999999.999", "KWD", Money{-999999999999999999, "KWD"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.amount, tt.currency)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v, want %v", tt.amount, tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyRejects(t *testing.T) {
+	tests := []struct {
+		name, amount, currency string
+	}{
+		// Amounts are never rounded: a digit the currency cannot hold is an
+		// error, even a zero.
+		{"excess decimals", "12.345", "USD"},
+		{"excess zero decimal", "12.340", "USD"},
+		{"decimals of a currency without minor units", "100.5", "JPY"},
+		{"fourth decimal of a three-digit currency", "1.2345", "BHD"},
+		{"unknown currency", "1.00", "XYZ"},
+		{"empty currency", "1.00", ""},
+		{"empty amount", "", "USD"},
+		{"sign only", "-", "USD"},
+		{"no whole part", ".5", "USD"},
+		{"double sign", "--1", "USD"},
+		{"plus sign", "+1", "USD"},
+		{"exponent", "1e3", "USD"},
+		{"second point", "1.2.3", "USD"},
+		{"thousands separator", "1,000", "USD"},
+		{"inner space", "1 000", "USD"},
+		{"decimal comma", "1,50", "EUR"},
+		{"non-ASCII digits", "١٢", "USD"},
+		{"overflow", "9999999999999999", "USD"},
+		{"int64 overflow", "99999999999999999999", "USD"},
+	}
+	for _, tt := range tests {
+		if got, err := ParseMoney(tt.amount, tt.currency); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("%s: ParseMoney(%q, %q) = %v, %v, want %v", tt.name, tt.amount, tt.currency, got, err, ErrInvalidMoney)
+		}
+	}
+}
+
+func TestMoneyDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{1250, "USD"}, "12.50"},
+		{Money{5, "USD"}, "0.05"},
+		{Money{0, "USD"}, "0.00"},
+		{Money{-1, "USD"}, "-0.01"},
+		{Money{-1250, "USD"}, "-12.50"},
+		{Money{1000, "JPY"}, "1000"},
+		{Money{-7, "JPY"}, "-7"},
+		{Money{1, "BHD"}, "0.001"},
+		{Money{math.MaxInt64, "USD"}, "92233720368547758.07"},
+		{Money{math.MinInt64, "USD"}, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("%#v.Decimal() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+// TestParseMoneyRoundTrips checks that parsing the formatted amount gives the
+// minor units back, so prices survive JSON and CSV unchanged.
+func TestParseMoneyRoundTrips(t *testing.T) {
+	for _, m := range []Money{
+		{1, "USD"}, {-1, "USD"}, {1999, "EUR"}, {42, "JPY"}, {1001, "KWD"},
+		{99999999999999999, "USD"}, {-999999999999999999, "BHD"},
+	} {
+		got, err := ParseMoney(m.Decimal(), m.Currency)
+		if err != nil || got != m {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v, want %v", m.Decimal(), m.Currency, got, err, m)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(Money{1250, "USD"})
+	if err != nil || string(data) != `{"amount":"12.50","currency":"USD"}` {
+		t.Fatalf("Marshal: got %s, %v", data, err)
+	}
+
+	for input, want := range map[string]Money{
+		`{"amount":"12.50","currency":"USD"}`: {1250, "USD"},
+		`{"amount":12.5,"currency":"usd"}`:    {1250, "USD"},
+		// A number is read from its text, so 0.29 is not 0.28999....
+		`{"amount":0.29,"currency":"EUR"}`: {29, "EUR"},
+	} {
+		var got Money
+		if err := json.Unmarshal([]byte(input), &got); err != nil || got != want {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{
+		`{"amount":"12.50"}`,
+		`{"currency":"USD"}`,
+		`{"amount":"","currency":"USD"}`,
+		`{"amount":12.505,"currency":"USD"}`,
+		`{"amount":1e2,"currency":"USD"}`,
+		`{"amount":true,"currency":"USD"}`,
+		`{"amount":"1","currency":"USD","rate":2}`,
+		`"12.50 USD"`,
+	} {
+		var got Money
+		if err := json.Unmarshal([]byte(input), &got); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", input, got, err, ErrInvalidMoney)
+		}
+	}
+}
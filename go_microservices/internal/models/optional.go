@@ -0,0 +1,33 @@
+package models
+
+import "encoding/json"
+
+// Optional records whether a JSON field was present and whether it was null,
+// which a plain value or a pointer cannot express at the same time. It is used
+// by merge-patch requests, where an absent field is left alone and null clears
+// it.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Present reports whether the field was sent with a non-null value.
+func (o Optional[T]) Present() bool {
+	return o.Set && !o.Null
+}
+
+// ValidationValue lets binding tags apply to the wrapped value; an absent or
+// null field counts as empty.
+func (o Optional[T]) ValidationValue() (interface{}, bool) {
+	return o.Value, o.Present()
+}
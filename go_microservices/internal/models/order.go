@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+type Order struct {
+	ID        int         `json:"id" db:"id"`
+	UserID    int         `json:"user_id" db:"user_id"`
+	Status    string      `json:"status" db:"status"`
+	Total     Money       `json:"total" db:"total_minor"`
+	Items     []OrderItem `json:"items"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+type OrderItem struct {
+	ID        int   `json:"id" db:"id"`
+	OrderID   int   `json:"order_id" db:"order_id"`
+	ProductID int   `json:"product_id" db:"product_id"`
+	Quantity  int   `json:"quantity" db:"quantity"`
+	UnitPrice Money `json:"unit_price" db:"unit_price_minor"`
+}
+
+// CreateOrderRequest places an order. UserID defaults to the caller and can
+// only name another user for staff. Currency picks the price list the items
+// are charged from; it defaults to the base currency.
+type CreateOrderRequest struct {
+	UserID   int                      `json:"user_id" binding:"omitempty,gt=0"`
+	Currency string                   `json:"currency,omitempty"`
+	Items    []CreateOrderItemRequest `json:"items" binding:"required,max=100"`
+}
+
+type CreateOrderItemRequest struct {
+	ProductID int `json:"product_id" binding:"required,gt=0"`
+	Quantity  int `json:"quantity" binding:"required,gt=0"`
+}
+
+const (
+	OrderStatusCreated = "created"
+)
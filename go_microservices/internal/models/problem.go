@@ -0,0 +1,16 @@
+package models
+
+import "go_microservices/internal/validate"
+
+// Problem is the body of every error response, an RFC 7807 problem details
+// object. Code is a stable identifier of the error that clients can match on
+// instead of the wording of Detail. Errors lists the invalid fields of a
+// request that failed validation.
+type Problem struct {
+	Type   string          `json:"type"`
+	Title  string          `json:"title"`
+	Status int             `json:"status"`
+	Detail string          `json:"detail,omitempty"`
+	Code   string          `json:"code"`
+	Errors validate.Errors `json:"errors,omitempty"`
+}
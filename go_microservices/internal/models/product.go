@@ -1,29 +1,137 @@
 package models
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Product struct {
-	ID          int       `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name" binding:"required"`
-	Description string    `json:"description" db:"description"`
-	Price       float64   `json:"price" db:"price" binding:"required,gt=0"`
-	Stock       int       `json:"stock" db:"stock" binding:"gte=0"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID          int        `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name" binding:"required"`
+	Description string     `json:"description" db:"description"`
+	Price       Money      `json:"price" db:"price_minor" binding:"required"`
+	Prices      []Money    `json:"prices,omitempty" db:"-"`
+	Stock       int        `json:"stock" db:"stock" binding:"gte=0"`
+	Reserved    int        `json:"reserved" db:"reserved"`
+	Available   int        `json:"available" db:"-"`
+	Version     int        `json:"version" db:"version"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// PriceIn returns the price of the product in currency: the base price or an
+// entry of its price list. Prices must have been loaded.
+func (p *Product) PriceIn(currency string) (Money, bool) {
+	if p.Price.Currency == currency {
+		return p.Price, true
+	}
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	return Money{}, false
 }
 
 type CreateProductRequest struct {
-	Name        string  `json:"name" binding:"required"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	Stock       int     `json:"stock" binding:"gte=0"`
+	Name        string `json:"name" binding:"required,max=200"`
+	Description string `json:"description"`
+	Price       Money  `json:"price" binding:"required"`
+	Stock       int    `json:"stock" binding:"gte=0"`
 }
 
+// UpdateProductRequest replaces every editable field of a product (PUT).
 type UpdateProductRequest struct {
-	Name        string  `json:"name" binding:"omitempty"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"omitempty,gt=0"`
-	Stock       int     `json:"stock" binding:"omitempty,gte=0"`
+	Name        string `json:"name" binding:"required,max=200"`
+	Description string `json:"description"`
+	Price       Money  `json:"price" binding:"required"`
+	Stock       int    `json:"stock" binding:"gte=0"`
+}
+
+// ProductPatch is a JSON Merge Patch (RFC 7396) of a product (PATCH). Absent
+// fields are left unchanged; a null description clears it.
+type ProductPatch struct {
+	Name        Optional[string] `json:"name" binding:"omitempty,max=200"`
+	Description Optional[string] `json:"description"`
+	Price       Optional[Money]  `json:"price"`
+	Stock       Optional[int]    `json:"stock" binding:"omitempty,gte=0"`
+}
+
+// ProductFilter narrows and orders GET /products results. Price bounds are
+// in minor units of the base currency; Category also matches products in its
+// subcategories.
+type ProductFilter struct {
+	Query    string `json:"q,omitempty"`
+	MinPrice *int64 `json:"min_price,omitempty"`
+	MaxPrice *int64 `json:"max_price,omitempty"`
+	InStock  *bool  `json:"in_stock,omitempty"`
+	Category *int   `json:"category,omitempty"`
+	Sort     string `json:"sort,omitempty"`
+	Order    string `json:"order,omitempty"`
+
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+}
+
+var productSortFields = map[string]bool{
+	"id":         true,
+	"price":      true,
+	"name":       true,
+	"created_at": true,
+}
+
+// Normalize trims and lowercases the filter and fills in default ordering so
+// that equivalent requests share a cache entry.
+func (f *ProductFilter) Normalize() {
+	f.Query = strings.Join(strings.Fields(strings.ToLower(f.Query)), " ")
+	f.Sort = strings.ToLower(strings.TrimSpace(f.Sort))
+	f.Order = strings.ToLower(strings.TrimSpace(f.Order))
+	if f.Sort == "" {
+		f.Sort = "id"
+	}
+	if f.Order == "" {
+		f.Order = "asc"
+	}
+}
+
+func (f *ProductFilter) Validate() error {
+	if !productSortFields[f.Sort] {
+		return fmt.Errorf("unsupported sort field %q", f.Sort)
+	}
+	if f.Order != "asc" && f.Order != "desc" {
+		return fmt.Errorf("unsupported sort order %q", f.Order)
+	}
+	if f.MinPrice != nil && *f.MinPrice < 0 {
+		return fmt.Errorf("min_price must not be negative")
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		return fmt.Errorf("min_price must not exceed max_price")
+	}
+	return nil
+}
+
+// CacheKey returns a canonical representation of a normalized filter.
+func (f ProductFilter) CacheKey() string {
+	parts := []string{"sort=" + f.Sort + ":" + f.Order}
+	if f.Query != "" {
+		parts = append(parts, "q="+f.Query)
+	}
+	if f.MinPrice != nil {
+		parts = append(parts, "min="+strconv.FormatInt(*f.MinPrice, 10))
+	}
+	if f.MaxPrice != nil {
+		parts = append(parts, "max="+strconv.FormatInt(*f.MaxPrice, 10))
+	}
+	if f.InStock != nil {
+		parts = append(parts, "in_stock="+strconv.FormatBool(*f.InStock))
+	}
+	if f.Category != nil {
+		parts = append(parts, "category="+strconv.Itoa(*f.Category))
+	}
+	if f.IncludeDeleted {
+		parts = append(parts, "include_deleted")
+	}
+	return strings.Join(parts, "&")
 }
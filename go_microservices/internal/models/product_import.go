@@ -0,0 +1,63 @@
+package models
+
+import "fmt"
+
+const (
+	ImportModeInsert = "insert"
+	ImportModeUpsert = "upsert"
+)
+
+const (
+	ImportActionCreated   = "created"
+	ImportActionUpdated   = "updated"
+	ImportActionUnchanged = "unchanged"
+)
+
+// ProductImportRow is one record of an import file. Line is its position in
+// the file; Error is set when the record could not be parsed. Fields left out
+// of the record keep their current value when it updates a product, and take
+// the CreateProductRequest defaults when it creates one.
+type ProductImportRow struct {
+	Line   int
+	Fields ProductPatch
+	Error  string
+}
+
+// ProductImportOptions controls how POST /products/import applies the file.
+// In upsert mode a row whose name matches an existing product replaces that
+// product instead of creating a new one.
+type ProductImportOptions struct {
+	Mode   string `json:"mode"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func (o *ProductImportOptions) Validate() error {
+	if o.Mode != ImportModeInsert && o.Mode != ImportModeUpsert {
+		return fmt.Errorf("unsupported import mode %q", o.Mode)
+	}
+	return nil
+}
+
+type ProductImportReport struct {
+	Mode      string                  `json:"mode"`
+	DryRun    bool                    `json:"dry_run"`
+	Total     int                     `json:"total"`
+	Created   int                     `json:"created"`
+	Updated   int                     `json:"updated"`
+	Unchanged int                     `json:"unchanged"`
+	Accepted  []ProductImportAccepted `json:"accepted"`
+	Rejected  []ProductImportRejected `json:"rejected"`
+}
+
+type ProductImportAccepted struct {
+	Line   int    `json:"line"`
+	ID     int    `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+type ProductImportRejected struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+type Reservation struct {
+	ID        int       `json:"id" db:"id"`
+	ProductID int       `json:"product_id" db:"product_id"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	Status    string    `json:"status" db:"status"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateReservationRequest holds units for TTLSeconds, at most a day, or for
+// the configured default.
+type CreateReservationRequest struct {
+	Quantity   int `json:"quantity" binding:"required,gt=0"`
+	TTLSeconds int `json:"ttl_seconds" binding:"omitempty,gt=0,lte=86400"`
+}
+
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCommitted = "committed"
+	ReservationStatusReleased  = "released"
+	ReservationStatusExpired   = "expired"
+)
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+type StockMovement struct {
+	ID             int64     `json:"id" db:"id"`
+	ProductID      int       `json:"product_id" db:"product_id"`
+	Reason         string    `json:"reason" db:"reason"`
+	Delta          int       `json:"delta" db:"delta"`
+	ResultingStock int       `json:"resulting_stock" db:"resulting_stock"`
+	Actor          string    `json:"actor" db:"actor"`
+	Reference      string    `json:"reference,omitempty" db:"reference"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type StockQuantityRequest struct {
+	Quantity int `json:"quantity" binding:"required,gt=0"`
+}
+
+const (
+	StockReasonInitial     = "initial"
+	StockReasonSale        = "sale"
+	StockReasonOrder       = "order"
+	StockReasonReservation = "reservation_commit"
+	StockReasonRestock     = "restock"
+	StockReasonAdjustment  = "adjustment"
+)
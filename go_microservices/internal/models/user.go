@@ -5,25 +5,65 @@ import (
 )
 
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name" binding:"required"`
-	Email     string    `json:"email" db:"email" binding:"required,email"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name" binding:"required"`
+	Email     string     `json:"email" db:"email" binding:"required,email"`
+	Role      string     `json:"role" db:"role"`
+	Version   int        `json:"version" db:"version"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// PasswordHash is only needed to log in, so only GetByEmail has to load
+	// it. It is never encoded and so never reaches responses or the cache.
+	PasswordHash string `json:"-" db:"password_hash"`
+}
+
+// Roles of users. Each role includes the permissions of the roles below it:
+// admin > staff > customer.
+const (
+	RoleAdmin    = "admin"
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+)
+
+var roleRanks = map[string]int{
+	RoleCustomer: 1,
+	RoleStaff:    2,
+	RoleAdmin:    3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleIncludes reports whether role has every permission of required. An
+// unknown role includes nothing.
+func RoleIncludes(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
 }
 
 type CreateUserRequest struct {
-	Name  string `json:"name" binding:"required"`
-	Email string `json:"email" binding:"required,email"`
+	Name  string `json:"name" binding:"required,max=100"`
+	Email string `json:"email" binding:"required,email,max=100"`
 }
 
+// UpdateUserRequest replaces every editable field of a user (PUT).
 type UpdateUserRequest struct {
-	Name  string `json:"name" binding:"omitempty"`
-	Email string `json:"email" binding:"omitempty,email"`
+	Name  string `json:"name" binding:"required,max=100"`
+	Email string `json:"email" binding:"required,email,max=100"`
+}
+
+// SetRoleRequest changes the role of a user.
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required"`
 }
 
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
-	Status  int    `json:"status"`
+// UserPatch is a JSON Merge Patch (RFC 7396) of a user (PATCH). Absent fields
+// are left unchanged.
+type UserPatch struct {
+	Name  Optional[string] `json:"name" binding:"omitempty,max=100"`
+	Email Optional[string] `json:"email" binding:"omitempty,email,max=100"`
 }
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many calls pass between sweeps of idle keys.
+const sweepEvery = 1000
+
+// MemoryLimiter keeps a sliding-window log per key in process memory. It lets
+// the service run without Redis; limits are per replica.
+type MemoryLimiter struct {
+	mu    sync.Mutex
+	logs  map[string][]time.Time
+	calls int
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{logs: make(map[string][]time.Time)}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return l.count(key, limit, true), nil
+}
+
+func (l *MemoryLimiter) Check(ctx context.Context, key string, limit Limit) (Result, error) {
+	return l.count(key, limit, false), nil
+}
+
+// count prunes the log of key and, if record is set and there is room,
+// appends the request to it.
+func (l *MemoryLimiter) count(key string, limit Limit, record bool) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now, limit.Window)
+	}
+
+	log := prune(l.logs[key], now.Add(-limit.Window))
+	allowed := len(log) < limit.Requests
+	if allowed && record {
+		log = append(log, now)
+	}
+	if len(log) == 0 {
+		delete(l.logs, key)
+		return Result{Allowed: allowed, Remaining: limit.Requests, Reset: limit.Window}
+	}
+	l.logs[key] = log
+
+	return Result{
+		Allowed:   allowed,
+		Remaining: limit.Requests - len(log),
+		Reset:     log[0].Add(limit.Window).Sub(now),
+	}
+}
+
+// sweep drops keys with no request in the last window. Keys of rules with a
+// longer window may be dropped early, which only makes their limit laxer.
+func (l *MemoryLimiter) sweep(now time.Time, window time.Duration) {
+	for key, log := range l.logs {
+		if len(log) == 0 || !log[len(log)-1].After(now.Add(-window)) {
+			delete(l.logs, key)
+		}
+	}
+}
+
+// prune drops the entries of a log that are not after since.
+func prune(log []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(log) && !log[i].After(since) {
+		i++
+	}
+	return log[i:]
+}
@@ -0,0 +1,100 @@
+// Package ratelimit limits how many requests an identity may make to a group
+// of routes within a sliding window.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverNone   = "none"
+)
+
+// Kinds of identities a limit applies to.
+const (
+	IdentityIP     = "ip"
+	IdentityUser   = "user"
+	IdentityAPIKey = "api_key"
+)
+
+// Limit allows Requests requests in any Window.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Policy formats the limit for the RateLimit-Policy header, e.g. "100;w=60".
+func (l Limit) Policy() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int(l.Window.Seconds()))
+}
+
+// Result is the outcome of counting a request against a limit.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until a request is allowed again, if it was denied,
+	// or until the window frees up a request otherwise.
+	Reset time.Duration
+}
+
+// Limiter counts requests under key. A request is only counted if it is
+// allowed.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	// Check reports whether a request under key would be allowed, without
+	// counting it.
+	Check(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Rules hold the limits per route group and identity kind. A group or kind
+// without a rule is not limited.
+type Rules map[string]map[string]Limit
+
+// Lookup returns the limit for an identity kind in a route group.
+func (r Rules) Lookup(group, identity string) (Limit, bool) {
+	limit, ok := r[group][identity]
+	return limit, ok
+}
+
+// ParseRules parses comma-separated rules of the form
+// <group>:<identity>=<requests>/<window>, e.g. "read:ip=60/1m,read:user=300/1m".
+func ParseRules(spec string) (Rules, error) {
+	rules := make(Rules)
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		target, value, ok := strings.Cut(rule, "=")
+		group, identity, ok2 := strings.Cut(target, ":")
+		requests, window, ok3 := strings.Cut(value, "/")
+		if !ok || !ok2 || !ok3 || group == "" {
+			return nil, fmt.Errorf("invalid rate limit rule %q", rule)
+		}
+		if identity != IdentityIP && identity != IdentityUser && identity != IdentityAPIKey {
+			return nil, fmt.Errorf("invalid rate limit rule %q: unknown identity %q", rule, identity)
+		}
+
+		n, err := strconv.Atoi(requests)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid rate limit rule %q: bad request count", rule)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid rate limit rule %q: window must be at least 1s", rule)
+		}
+
+		if rules[group] == nil {
+			rules[group] = make(map[string]Limit)
+		}
+		rules[group][identity] = Limit{Requests: n, Window: d}
+	}
+	return rules, nil
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(" read:ip=60/1m, read:user=300/1m,,write:api_key=10/1s ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Rules{
+		"read": {
+			IdentityIP:   {Requests: 60, Window: time.Minute},
+			IdentityUser: {Requests: 300, Window: time.Minute},
+		},
+		"write": {IdentityAPIKey: {Requests: 10, Window: time.Second}},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("got %v, want %v", rules, want)
+	}
+	if _, ok := rules.Lookup("write", IdentityIP); ok {
+		t.Fatal("found a limit for an identity without a rule")
+	}
+	if limit, _ := rules.Lookup("read", IdentityIP); limit.Policy() != "60;w=60" {
+		t.Fatalf("policy %q", limit.Policy())
+	}
+
+	for _, spec := range []string{
+		"read=60/1m",
+		":ip=60/1m",
+		"read:ip",
+		"read:ip=60",
+		"read:session=60/1m",
+		"read:ip=0/1m",
+		"read:ip=x/1m",
+		"read:ip=60/500ms",
+		"read:ip=60/minute",
+	} {
+		if _, err := ParseRules(spec); err == nil {
+			t.Errorf("ParseRules(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := NewMemoryLimiter()
+	limit := Limit{Requests: 2, Window: 50 * time.Millisecond}
+
+	if r, _ := l.Check(ctx, "k", limit); !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("check of a fresh key: %+v", r)
+	}
+	for want := 1; want >= 0; want-- {
+		if r, _ := l.Allow(ctx, "k", limit); !r.Allowed || r.Remaining != want {
+			t.Fatalf("got %+v, want %d remaining", r, want)
+		}
+	}
+	r, _ := l.Allow(ctx, "k", limit)
+	if r.Allowed || r.Reset <= 0 || r.Reset > limit.Window {
+		t.Fatalf("a request over the limit: %+v", r)
+	}
+	if r, _ := l.Allow(ctx, "other", limit); !r.Allowed {
+		t.Fatal("keys share a limit")
+	}
+
+	// Denied requests do not count, so the window frees up on schedule.
+	time.Sleep(limit.Window + 10*time.Millisecond)
+	if r, _ := l.Allow(ctx, "k", limit); !r.Allowed || r.Remaining != 1 {
+		t.Fatalf("after the window: %+v", r)
+	}
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type APIKeyRepository struct {
+	store *Store
+}
+
+func NewAPIKeyRepository(store *Store) *APIKeyRepository {
+	return &APIKeyRepository{store: store}
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if key.CreatedBy != nil {
+		if _, ok := r.store.users[*key.CreatedBy]; !ok {
+			return ErrForeignKey
+		}
+	}
+	for _, k := range r.store.apiKeys {
+		if k.KeyHash == key.KeyHash {
+			return repository.ErrDuplicate
+		}
+	}
+
+	r.store.nextAPIKeyID++
+	key.ID = r.store.nextAPIKeyID
+	key.CreatedAt = now()
+	r.store.apiKeys[key.ID] = copyAPIKey(*key)
+	return nil
+}
+
+func (r *APIKeyRepository) GetByID(id int) (*models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	key, ok := r.store.apiKeys[id]
+	if !ok {
+		return nil, nil
+	}
+	key = copyAPIKey(key)
+	return &key, nil
+}
+
+// GetByHash finds a key by the hash of the key, including revoked and expired
+// keys.
+func (r *APIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, key := range r.store.apiKeys {
+		if key.KeyHash == keyHash {
+			key = copyAPIKey(key)
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAll returns every key, revoked ones included, newest first.
+func (r *APIKeyRepository) GetAll() ([]models.APIKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(r.store.apiKeys))
+	for _, key := range r.store.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+	return keys, nil
+}
+
+// Revoke marks the key as revoked. Revoking a revoked key keeps the original
+// time. It returns sql.ErrNoRows if there is no such key.
+func (r *APIKeyRepository) Revoke(id int) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key, ok := r.store.apiKeys[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if key.RevokedAt == nil {
+		at := now()
+		key.RevokedAt = &at
+		r.store.apiKeys[id] = key
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(id int, at time.Time) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if key, ok := r.store.apiKeys[id]; ok {
+		at = at.Truncate(time.Microsecond)
+		key.LastUsedAt = &at
+		r.store.apiKeys[id] = key
+	}
+	return nil
+}
+
+// copyAPIKey keeps callers from sharing the scopes slice with the store.
+func copyAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = append([]string{}, key.Scopes...)
+	return key
+}
@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type CategoryRepository struct {
+	store *Store
+}
+
+func NewCategoryRepository(store *Store) *CategoryRepository {
+	return &CategoryRepository{store: store}
+}
+
+func (r *CategoryRepository) BeginTx() (repository.Tx, error) {
+	return r.store.begin()
+}
+
+// LockTx is a no-op: the transaction already serializes every write.
+func (r *CategoryRepository) LockTx(tx repository.Tx) error {
+	return nil
+}
+
+func (r *CategoryRepository) CreateTx(tx repository.Tx, category *models.Category) error {
+	var err error
+	r.store.write(tx, func() func() {
+		if category.ParentID != nil {
+			if _, ok := r.store.categories[*category.ParentID]; !ok {
+				err = ErrForeignKey
+				return nil
+			}
+		}
+
+		r.store.nextCategoryID++
+		category.ID = r.store.nextCategoryID
+		category.CreatedAt = now()
+		category.UpdatedAt = category.CreatedAt
+		r.store.categories[category.ID] = *category
+
+		id := category.ID
+		return func() { delete(r.store.categories, id) }
+	})
+	return err
+}
+
+// GetAll returns every category ordered by name, parents and children alike.
+func (r *CategoryRepository) GetAll() ([]models.Category, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	categories := make([]models.Category, 0, len(r.store.categories))
+	for _, c := range r.store.categories {
+		categories = append(categories, c)
+	}
+	sortCategories(categories)
+	return categories, nil
+}
+
+// GetPathTx returns id followed by the IDs of its ancestors up to the root,
+// or nothing if there is no such category.
+func (r *CategoryRepository) GetPathTx(tx repository.Tx, id int) ([]int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var path []int
+	for {
+		c, ok := r.store.categories[id]
+		if !ok {
+			return path, nil
+		}
+		path = append(path, id)
+		if c.ParentID == nil {
+			return path, nil
+		}
+		id = *c.ParentID
+	}
+}
+
+// CountTx counts how many of ids are existing categories.
+func (r *CategoryRepository) CountTx(tx repository.Tx, ids []int) (int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	count := 0
+	for _, id := range ids {
+		if _, ok := r.store.categories[id]; ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *CategoryRepository) HasChildrenTx(tx repository.Tx, id int) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, c := range r.store.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UpdateTx renames and moves a category. It returns sql.ErrNoRows if there is
+// no such category.
+func (r *CategoryRepository) UpdateTx(tx repository.Tx, category *models.Category) error {
+	var err error
+	r.store.write(tx, func() func() {
+		existing, ok := r.store.categories[category.ID]
+		if !ok {
+			err = sql.ErrNoRows
+			return nil
+		}
+
+		updated := existing
+		updated.ParentID = category.ParentID
+		updated.Name = category.Name
+		updated.UpdatedAt = now()
+		r.store.categories[category.ID] = updated
+		*category = updated
+
+		return func() { r.store.categories[existing.ID] = existing }
+	})
+	return err
+}
+
+// DeleteTx removes a category without subcategories; its product links go
+// with it. It returns sql.ErrNoRows if there is no such category.
+func (r *CategoryRepository) DeleteTx(tx repository.Tx, id int) error {
+	var err error
+	r.store.write(tx, func() func() {
+		existing, ok := r.store.categories[id]
+		if !ok {
+			err = sql.ErrNoRows
+			return nil
+		}
+
+		delete(r.store.categories, id)
+		var linked []int
+		for productID, links := range r.store.links {
+			if links[id] {
+				delete(links, id)
+				linked = append(linked, productID)
+			}
+		}
+
+		return func() {
+			r.store.categories[id] = existing
+			for _, productID := range linked {
+				r.store.links[productID][id] = true
+			}
+		}
+	})
+	return err
+}
+
+// GetByProductID returns the categories a product is linked to, ordered by
+// name. It returns sql.ErrNoRows if there is no such product.
+func (r *CategoryRepository) GetByProductID(productID int) ([]models.Category, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	if p, ok := r.store.products[productID]; !ok || p.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	categories := []models.Category{}
+	for id := range r.store.links[productID] {
+		categories = append(categories, r.store.categories[id])
+	}
+	sortCategories(categories)
+	return categories, nil
+}
+
+// SetProductCategoriesTx replaces the categories of a product. It returns
+// sql.ErrNoRows if there is no such product.
+func (r *CategoryRepository) SetProductCategoriesTx(tx repository.Tx, productID int, categoryIDs []int) error {
+	var err error
+	r.store.write(tx, func() func() {
+		if p, ok := r.store.products[productID]; !ok || p.DeletedAt != nil {
+			err = sql.ErrNoRows
+			return nil
+		}
+
+		links := make(map[int]bool, len(categoryIDs))
+		for _, id := range categoryIDs {
+			if _, ok := r.store.categories[id]; !ok {
+				err = ErrForeignKey
+				return nil
+			}
+			links[id] = true
+		}
+
+		previous, existed := r.store.links[productID]
+		r.store.links[productID] = links
+
+		return func() {
+			if existed {
+				r.store.links[productID] = previous
+			} else {
+				delete(r.store.links, productID)
+			}
+		}
+	})
+	return err
+}
+
+// categorySubtree returns the ID of a category and the IDs of all its
+// descendants. Must be called with mu held.
+func (s *Store) categorySubtree(id int) map[int]bool {
+	subtree := map[int]bool{id: true}
+	for grown := true; grown; {
+		grown = false
+		for _, c := range s.categories {
+			if c.ParentID != nil && subtree[*c.ParentID] && !subtree[c.ID] {
+				subtree[c.ID] = true
+				grown = true
+			}
+		}
+	}
+	return subtree
+}
+
+// linkedToAny reports whether a product is linked to one of categories. Must
+// be called with mu held.
+func (s *Store) linkedToAny(productID int, categories map[int]bool) bool {
+	for id := range s.links[productID] {
+		if categories[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortCategories(categories []models.Category) {
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].ID < categories[j].ID
+	})
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"sort"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type OrderRepository struct {
+	store *Store
+}
+
+func NewOrderRepository(store *Store) *OrderRepository {
+	return &OrderRepository{store: store}
+}
+
+func (r *OrderRepository) BeginTx() (repository.Tx, error) {
+	return r.store.begin()
+}
+
+// CreateTx inserts the order and its items inside the caller's transaction.
+func (r *OrderRepository) CreateTx(tx repository.Tx, order *models.Order) error {
+	var err error
+	r.store.write(tx, func() func() {
+		if _, ok := r.store.users[order.UserID]; !ok {
+			err = ErrForeignKey
+			return nil
+		}
+		for _, item := range order.Items {
+			if _, ok := r.store.products[item.ProductID]; !ok {
+				err = ErrForeignKey
+				return nil
+			}
+		}
+
+		r.store.nextOrderID++
+		order.ID = r.store.nextOrderID
+		order.CreatedAt = now()
+		order.UpdatedAt = order.CreatedAt
+		for i := range order.Items {
+			r.store.nextOrderItemID++
+			order.Items[i].ID = r.store.nextOrderItemID
+			order.Items[i].OrderID = order.ID
+		}
+		r.store.orders[order.ID] = cloneOrder(*order)
+
+		id := order.ID
+		return func() { delete(r.store.orders, id) }
+	})
+	return err
+}
+
+func (r *OrderRepository) GetByID(id int) (*models.Order, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	order, ok := r.store.orders[id]
+	if !ok {
+		return nil, nil
+	}
+	order = cloneOrder(order)
+	return &order, nil
+}
+
+func (r *OrderRepository) GetByUserID(userID, limit, offset int) ([]models.Order, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	orders := []models.Order{}
+	for _, order := range r.store.orders {
+		if order.UserID == userID {
+			orders = append(orders, cloneOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID > orders[j].ID })
+	return paginate(orders, limit, offset), nil
+}
+
+func (r *OrderRepository) CountByUserID(userID int) (int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	count := 0
+	for _, order := range r.store.orders {
+		if order.UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// cloneOrder copies the items so callers cannot mutate stored state.
+func cloneOrder(order models.Order) models.Order {
+	order.Items = append([]models.OrderItem{}, order.Items...)
+	return order
+}
@@ -0,0 +1,30 @@
+package memory
+
+// paginate applies LIMIT/OFFSET to rows in display order and never returns a
+// nil slice.
+func paginate[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return []T{}
+	}
+	rows = rows[offset:]
+	if limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return append([]T{}, rows...)
+}
+
+// firstN returns up to n rows from the start and whether more follow.
+func firstN[T any](rows []T, n int) ([]T, bool, error) {
+	if len(rows) > n {
+		return append([]T{}, rows[:n]...), true, nil
+	}
+	return append([]T{}, rows...), false, nil
+}
+
+// lastN returns up to n rows from the end and whether more precede them.
+func lastN[T any](rows []T, n int) ([]T, bool, error) {
+	if len(rows) > n {
+		return append([]T{}, rows[len(rows)-n:]...), true, nil
+	}
+	return append([]T{}, rows...), false, nil
+}
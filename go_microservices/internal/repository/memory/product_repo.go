@@ -0,0 +1,512 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type ProductRepository struct {
+	store *Store
+}
+
+func NewProductRepository(store *Store) *ProductRepository {
+	return &ProductRepository{store: store}
+}
+
+func (r *ProductRepository) BeginTx() (repository.Tx, error) {
+	return r.store.begin()
+}
+
+func (r *ProductRepository) CreateTx(tx repository.Tx, product *models.Product) error {
+	r.store.write(tx, func() func() {
+		r.store.nextProductID++
+		product.ID = r.store.nextProductID
+		product.Version = 1
+		product.CreatedAt = now()
+		product.UpdatedAt = product.CreatedAt
+		r.store.products[product.ID] = *product
+
+		id := product.ID
+		return func() { delete(r.store.products, id) }
+	})
+	return nil
+}
+
+func (r *ProductRepository) CreateManyTx(tx repository.Tx, products []*models.Product) error {
+	for _, product := range products {
+		if err := r.CreateTx(tx, product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ProductRepository) GetByID(id int) (*models.Product, error) {
+	product, err := r.GetByIDWithDeleted(id)
+	if product == nil || product.DeletedAt != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// GetByIDWithDeleted also finds soft-deleted products.
+func (r *ProductRepository) GetByIDWithDeleted(id int) (*models.Product, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	product, ok := r.store.products[id]
+	if !ok {
+		return nil, nil
+	}
+	product = r.store.withAvailability(product)
+	return &product, nil
+}
+
+// GetByIDForUpdate needs no row lock: tx already serializes writers.
+func (r *ProductRepository) GetByIDForUpdate(tx repository.Tx, id int) (*models.Product, error) {
+	return r.GetByID(id)
+}
+
+func (r *ProductRepository) GetAll(filter models.ProductFilter, limit, offset int) ([]models.Product, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return paginate(r.filtered(filter), limit, offset), nil
+}
+
+// GetPage follows the contract of the postgres implementation: rows come back
+// in display order, and the flag reports more rows in the direction of travel.
+func (r *ProductRepository) GetPage(filter models.ProductFilter, cursor *models.Cursor, limit int) ([]models.Product, bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	products := r.filtered(filter)
+	if cursor == nil {
+		return firstN(products, limit)
+	}
+
+	if cursor.Backward {
+		before := sort.Search(len(products), func(i int) bool {
+			return compareToCursor(filter, products[i], cursor) >= 0
+		})
+		return lastN(products[:before], limit)
+	}
+	after := sort.Search(len(products), func(i int) bool {
+		return compareToCursor(filter, products[i], cursor) > 0
+	})
+	return firstN(products[after:], limit)
+}
+
+// GetByNamesForUpdate returns the products with any of the given names in id
+// order.
+func (r *ProductRepository) GetByNamesForUpdate(tx repository.Tx, names []string) ([]models.Product, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var products []models.Product
+	for _, p := range r.store.products {
+		if p.DeletedAt == nil && wanted[p.Name] {
+			products = append(products, r.store.withAvailability(p))
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products, nil
+}
+
+// Export calls fn for every product matching filter, in id order, until ctx
+// ends. It works on a snapshot taken up front, so fn may take as long as it
+// needs.
+func (r *ProductRepository) Export(ctx context.Context, filter models.ExportFilter, fn func(models.Product) error) error {
+	r.store.mu.RLock()
+	products := r.filtered(models.ProductFilter{Sort: "id", Order: "asc", IncludeDeleted: filter.IncludeDeleted})
+	r.store.mu.RUnlock()
+
+	for _, product := range products {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if filter.UpdatedSince != nil && product.UpdatedAt.Before(*filter.UpdatedSince) {
+			continue
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateTx replaces the editable fields of the product. A non-zero version
+// makes the write conditional on the row still being at that version.
+func (r *ProductRepository) UpdateTx(tx repository.Tx, id int, product *models.Product, version int) error {
+	updated, err := r.updateTx(tx, id, version, func(p *models.Product) {
+		p.Name = product.Name
+		p.Description = product.Description
+		p.Price = product.Price
+		p.Stock = product.Stock
+	})
+	if err != nil {
+		return err
+	}
+
+	product.Version = updated.Version
+	product.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+// UpdateManyTx replaces the fields of several products, each matched by its
+// ID and Version like UpdateTx.
+func (r *ProductRepository) UpdateManyTx(tx repository.Tx, products []*models.Product) error {
+	for _, product := range products {
+		if err := r.UpdateTx(tx, product.ID, product, product.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PatchTx updates only the fields present in patch, under the same version
+// rule as UpdateTx. A null description is stored as empty.
+func (r *ProductRepository) PatchTx(tx repository.Tx, id int, patch *models.ProductPatch, version int) error {
+	_, err := r.updateTx(tx, id, version, func(p *models.Product) {
+		if patch.Name.Set {
+			p.Name = patch.Name.Value
+		}
+		if patch.Description.Set {
+			p.Description = patch.Description.Value
+		}
+		if patch.Price.Set {
+			p.Price = patch.Price.Value
+		}
+		if patch.Stock.Set {
+			p.Stock = patch.Stock.Value
+		}
+	})
+	return err
+}
+
+func (r *ProductRepository) updateTx(tx repository.Tx, id, version int, apply func(p *models.Product)) (models.Product, error) {
+	var updated models.Product
+	var err error
+	r.store.write(tx, func() func() {
+		existing, ok := r.store.products[id]
+		if !ok || existing.DeletedAt != nil {
+			err = sql.ErrNoRows
+			return nil
+		}
+		if version != 0 && existing.Version != version {
+			err = repository.ErrVersionConflict
+			return nil
+		}
+
+		updated = existing
+		apply(&updated)
+		updated.Version++
+		updated.UpdatedAt = now()
+		r.store.products[id] = updated
+		return func() { r.store.products[id] = existing }
+	})
+	return updated, err
+}
+
+// UpdateStockTx decrements stock as part of the caller's transaction and
+// returns the resulting stock.
+func (r *ProductRepository) UpdateStockTx(tx repository.Tx, id, quantity int) (int, error) {
+	var newStock int
+	var err error
+	r.store.write(tx, func() func() {
+		existing, ok := r.store.products[id]
+		if !ok || existing.DeletedAt != nil || existing.Stock-r.store.reserved(id) < quantity {
+			err = fmt.Errorf("insufficient stock or product not found")
+			return nil
+		}
+
+		updated := existing
+		updated.Stock -= quantity
+		updated.Version++
+		updated.UpdatedAt = now()
+		r.store.products[id] = updated
+
+		newStock = updated.Stock
+		return func() { r.store.products[id] = existing }
+	})
+	return newStock, err
+}
+
+// RestockTx increments stock as part of the caller's transaction and returns
+// the resulting stock.
+func (r *ProductRepository) RestockTx(tx repository.Tx, id, quantity int) (int, error) {
+	var newStock int
+	var err error
+	r.store.write(tx, func() func() {
+		existing, ok := r.store.products[id]
+		if !ok || existing.DeletedAt != nil {
+			err = sql.ErrNoRows
+			return nil
+		}
+
+		updated := existing
+		updated.Stock += quantity
+		updated.Version++
+		updated.UpdatedAt = now()
+		r.store.products[id] = updated
+
+		newStock = updated.Stock
+		return func() { r.store.products[id] = existing }
+	})
+	return newStock, err
+}
+
+// GetPrices returns the price list of a product ordered by currency.
+func (r *ProductRepository) GetPrices(productID int) ([]models.Money, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var prices []models.Money
+	for currency, amount := range r.store.prices[productID] {
+		prices = append(prices, models.Money{Amount: amount, Currency: currency})
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Currency < prices[j].Currency })
+	return prices, nil
+}
+
+// SetPriceTx adds price to the price list of a product or replaces the entry
+// in the same currency.
+func (r *ProductRepository) SetPriceTx(tx repository.Tx, productID int, price models.Money) error {
+	var err error
+	r.store.write(tx, func() func() {
+		if _, ok := r.store.products[productID]; !ok {
+			err = ErrForeignKey
+			return nil
+		}
+
+		prices := r.store.prices[productID]
+		if prices == nil {
+			prices = make(map[string]int64)
+			r.store.prices[productID] = prices
+		}
+		previous, existed := prices[price.Currency]
+		prices[price.Currency] = price.Amount
+
+		return func() {
+			if existed {
+				prices[price.Currency] = previous
+			} else {
+				delete(prices, price.Currency)
+			}
+		}
+	})
+	return err
+}
+
+// DeletePriceTx removes the entry in currency from the price list of a
+// product. It returns sql.ErrNoRows if there is none.
+func (r *ProductRepository) DeletePriceTx(tx repository.Tx, productID int, currency string) error {
+	var err error
+	r.store.write(tx, func() func() {
+		prices := r.store.prices[productID]
+		previous, ok := prices[currency]
+		if !ok {
+			err = sql.ErrNoRows
+			return nil
+		}
+
+		delete(prices, currency)
+		return func() { prices[currency] = previous }
+	})
+	return err
+}
+
+// Delete soft-deletes the product under the same version rule as UpdateTx.
+// Its reservations and stock history are kept.
+func (r *ProductRepository) Delete(id, version int) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+
+	_, err := r.updateTx(nil, id, version, func(p *models.Product) {
+		at := now()
+		p.DeletedAt = &at
+	})
+	return err
+}
+
+// Restore undoes a soft delete. It returns sql.ErrNoRows unless the product
+// exists and is deleted.
+func (r *ProductRepository) Restore(id int) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	product, ok := r.store.products[id]
+	if !ok || product.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+
+	product.DeletedAt = nil
+	product.Version++
+	product.UpdatedAt = now()
+	r.store.products[id] = product
+	return nil
+}
+
+// Purge hard-deletes products soft-deleted before the given time, together
+// with their reservations, prices and category links, as the ON DELETE
+// CASCADE foreign keys do. Products referenced by orders or stock movements
+// are kept; kept is how many of them were due.
+func (r *ProductRepository) Purge(before time.Time) (purged, kept int64, err error) {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	referenced := make(map[int]bool)
+	for _, order := range r.store.orders {
+		for _, item := range order.Items {
+			referenced[item.ProductID] = true
+		}
+	}
+	for _, m := range r.store.movements {
+		referenced[m.ProductID] = true
+	}
+
+	removed := make(map[int]bool)
+	for id, p := range r.store.products {
+		if p.DeletedAt == nil || !p.DeletedAt.Before(before) {
+			continue
+		}
+		if referenced[id] {
+			kept++
+			continue
+		}
+		delete(r.store.products, id)
+		removed[id] = true
+	}
+	for resID, res := range r.store.reservations {
+		if removed[res.ProductID] {
+			delete(r.store.reservations, resID)
+		}
+	}
+	for id := range removed {
+		delete(r.store.prices, id)
+		delete(r.store.links, id)
+	}
+	return int64(len(removed)), kept, nil
+}
+
+func (r *ProductRepository) Count(filter models.ProductFilter) (int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return len(r.filtered(filter)), nil
+}
+
+// filtered returns the products matching filter in display order. Must be
+// called with mu held.
+func (r *ProductRepository) filtered(filter models.ProductFilter) []models.Product {
+	terms := strings.Fields(strings.ToLower(filter.Query))
+
+	var categories map[int]bool
+	if filter.Category != nil {
+		categories = r.store.categorySubtree(*filter.Category)
+	}
+
+	products := []models.Product{}
+	for _, p := range r.store.products {
+		if p.DeletedAt != nil && !filter.IncludeDeleted {
+			continue
+		}
+		p = r.store.withAvailability(p)
+		if !matchesTerms(p, terms) {
+			continue
+		}
+		if filter.MinPrice != nil && p.Price.Amount < *filter.MinPrice {
+			continue
+		}
+		if filter.MaxPrice != nil && p.Price.Amount > *filter.MaxPrice {
+			continue
+		}
+		if filter.InStock != nil && (p.Available > 0) != *filter.InStock {
+			continue
+		}
+		if categories != nil && !r.store.linkedToAny(p.ID, categories) {
+			continue
+		}
+		products = append(products, p)
+	}
+
+	sort.Slice(products, func(i, j int) bool {
+		return compareProducts(filter, products[i], products[j]) < 0
+	})
+	return products
+}
+
+// matchesTerms approximates the full-text search of the postgres backend:
+// every term must appear in the name or description, ignoring case.
+func matchesTerms(p models.Product, terms []string) bool {
+	text := strings.ToLower(p.Name + " " + p.Description)
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareProducts orders products by the sort field with id as a tiebreaker,
+// honouring the sort direction.
+func compareProducts(filter models.ProductFilter, a, b models.Product) int {
+	c := 0
+	switch filter.Sort {
+	case "price":
+		c = compareOrdered(a.Price.Amount, b.Price.Amount)
+	case "name":
+		c = strings.Compare(a.Name, b.Name)
+	case "created_at":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if c == 0 {
+		c = compareOrdered(a.ID, b.ID)
+	}
+	if filter.Order == "desc" {
+		return -c
+	}
+	return c
+}
+
+// compareToCursor places p relative to the cursor position in display order.
+func compareToCursor(filter models.ProductFilter, p models.Product, cursor *models.Cursor) int {
+	pivot := models.Product{ID: cursor.ID}
+	switch filter.Sort {
+	case "price":
+		pivot.Price.Amount, _ = strconv.ParseInt(cursor.Value, 10, 64)
+	case "name":
+		pivot.Name = cursor.Value
+	case "created_at":
+		pivot.CreatedAt, _ = time.Parse(time.RFC3339Nano, cursor.Value)
+	}
+	return compareProducts(filter, p, pivot)
+}
+
+func compareOrdered[T int | int64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
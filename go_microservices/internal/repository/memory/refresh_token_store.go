@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type refreshToken struct {
+	userID    int
+	expiresAt time.Time
+}
+
+// RefreshTokenStore keeps refresh tokens in process memory. Tokens are lost on
+// restart and not shared between replicas.
+type RefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]refreshToken
+}
+
+func NewRefreshTokenStore() *RefreshTokenStore {
+	return &RefreshTokenStore{tokens: make(map[string]refreshToken)}
+}
+
+func (s *RefreshTokenStore) Save(ctx context.Context, tokenHash string, userID int, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at := time.Now()
+	for hash, token := range s.tokens {
+		if !token.expiresAt.After(at) {
+			delete(s.tokens, hash)
+		}
+	}
+	s.tokens[tokenHash] = refreshToken{userID: userID, expiresAt: at.Add(ttl)}
+	return nil
+}
+
+func (s *RefreshTokenStore) Consume(ctx context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return 0, nil
+	}
+	delete(s.tokens, tokenHash)
+	if !token.expiresAt.After(time.Now()) {
+		return 0, nil
+	}
+	return token.userID, nil
+}
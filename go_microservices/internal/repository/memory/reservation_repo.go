@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type ReservationRepository struct {
+	store *Store
+}
+
+func NewReservationRepository(store *Store) *ReservationRepository {
+	return &ReservationRepository{store: store}
+}
+
+func (r *ReservationRepository) BeginTx() (repository.Tx, error) {
+	return r.store.begin()
+}
+
+func (r *ReservationRepository) CreateTx(tx repository.Tx, reservation *models.Reservation) error {
+	var err error
+	r.store.write(tx, func() func() {
+		if _, ok := r.store.products[reservation.ProductID]; !ok {
+			err = ErrForeignKey
+			return nil
+		}
+
+		r.store.nextReservationID++
+		reservation.ID = r.store.nextReservationID
+		reservation.CreatedAt = now()
+		reservation.UpdatedAt = reservation.CreatedAt
+		r.store.reservations[reservation.ID] = *reservation
+
+		id := reservation.ID
+		return func() { delete(r.store.reservations, id) }
+	})
+	return err
+}
+
+func (r *ReservationRepository) GetByID(id int) (*models.Reservation, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	res, ok := r.store.reservations[id]
+	if !ok {
+		return nil, nil
+	}
+	return &res, nil
+}
+
+// GetByIDForUpdate needs no row lock: tx already serializes writers.
+func (r *ReservationRepository) GetByIDForUpdate(tx repository.Tx, id int) (*models.Reservation, error) {
+	return r.GetByID(id)
+}
+
+func (r *ReservationRepository) UpdateStatusTx(tx repository.Tx, reservation *models.Reservation) error {
+	var err error
+	r.store.write(tx, func() func() {
+		existing, ok := r.store.reservations[reservation.ID]
+		if !ok {
+			err = sql.ErrNoRows
+			return nil
+		}
+
+		updated := existing
+		updated.Status = reservation.Status
+		updated.UpdatedAt = now()
+		r.store.reservations[reservation.ID] = updated
+
+		reservation.UpdatedAt = updated.UpdatedAt
+		return func() { r.store.reservations[existing.ID] = existing }
+	})
+	return err
+}
+
+// ExpireDue marks every active reservation past its deadline as expired and
+// returns the IDs of the affected products.
+func (r *ReservationRepository) ExpireDue(at time.Time) ([]int, error) {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var due []models.Reservation
+	for _, res := range r.store.reservations {
+		if res.Status == models.ReservationStatusActive && !res.ExpiresAt.After(at) {
+			due = append(due, res)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+
+	seen := make(map[int]bool)
+	var productIDs []int
+	updatedAt := now()
+	for _, res := range due {
+		res.Status = models.ReservationStatusExpired
+		res.UpdatedAt = updatedAt
+		r.store.reservations[res.ID] = res
+
+		if !seen[res.ProductID] {
+			seen[res.ProductID] = true
+			productIDs = append(productIDs, res.ProductID)
+		}
+	}
+	return productIDs, nil
+}
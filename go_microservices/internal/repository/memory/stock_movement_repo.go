@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type StockMovementRepository struct {
+	store *Store
+}
+
+func NewStockMovementRepository(store *Store) *StockMovementRepository {
+	return &StockMovementRepository{store: store}
+}
+
+// CreateTx appends a movement inside the transaction that changed the stock.
+// Movements are never modified afterwards.
+func (r *StockMovementRepository) CreateTx(tx repository.Tx, movement *models.StockMovement) error {
+	var err error
+	r.store.write(tx, func() func() {
+		if _, ok := r.store.products[movement.ProductID]; !ok {
+			err = ErrForeignKey
+			return nil
+		}
+
+		r.store.nextMovementID++
+		movement.ID = r.store.nextMovementID
+		movement.CreatedAt = now()
+		r.store.movements = append(r.store.movements, *movement)
+
+		id := movement.ID
+		return func() {
+			for i, m := range r.store.movements {
+				if m.ID == id {
+					r.store.movements = append(r.store.movements[:i], r.store.movements[i+1:]...)
+					return
+				}
+			}
+		}
+	})
+	return err
+}
+
+func (r *StockMovementRepository) CreateManyTx(tx repository.Tx, movements []*models.StockMovement) error {
+	for _, movement := range movements {
+		if err := r.CreateTx(tx, movement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByProductID returns the newest movements first.
+func (r *StockMovementRepository) GetByProductID(productID, limit, offset int) ([]models.StockMovement, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	movements := []models.StockMovement{}
+	for i := len(r.store.movements) - 1; i >= 0; i-- {
+		if r.store.movements[i].ProductID == productID {
+			movements = append(movements, r.store.movements[i])
+		}
+	}
+	return paginate(movements, limit, offset), nil
+}
+
+func (r *StockMovementRepository) CountByProductID(productID int) (int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	count := 0
+	for _, m := range r.store.movements {
+		if m.ProductID == productID {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+	"go_microservices/internal/service"
+)
+
+var (
+	_ service.UserRepository          = (*UserRepository)(nil)
+	_ service.ProductRepository       = (*ProductRepository)(nil)
+	_ service.CategoryRepository      = (*CategoryRepository)(nil)
+	_ service.OrderRepository         = (*OrderRepository)(nil)
+	_ service.ReservationRepository   = (*ReservationRepository)(nil)
+	_ service.StockMovementRepository = (*StockMovementRepository)(nil)
+	_ service.APIKeyRepository        = (*APIKeyRepository)(nil)
+	_ service.RefreshTokenStore       = (*RefreshTokenStore)(nil)
+)
+
+// The errors mirror what the postgres repositories return for the same
+// constraint violations.
+var (
+	ErrDuplicateEmail = fmt.Errorf("%w: users_email_key", repository.ErrDuplicate)
+	ErrForeignKey     = repository.ErrForeignKey
+)
+
+// Store holds every table of the in-memory backend. Repositories created from
+// the same Store share data and transactions, like repositories sharing one
+// *sql.DB.
+//
+// Writes are serialized by txMu: a transaction holds it from BeginTx until
+// Commit or Rollback, and standalone writes hold it for their duration. Reads
+// only take mu, so they may observe uncommitted changes.
+type Store struct {
+	txMu sync.Mutex
+	mu   sync.RWMutex
+
+	users        map[int]models.User
+	products     map[int]models.Product
+	prices       map[int]map[string]int64
+	categories   map[int]models.Category
+	links        map[int]map[int]bool
+	orders       map[int]models.Order
+	reservations map[int]models.Reservation
+	movements    []models.StockMovement
+	apiKeys      map[int]models.APIKey
+
+	nextUserID        int
+	nextProductID     int
+	nextCategoryID    int
+	nextOrderID       int
+	nextOrderItemID   int
+	nextReservationID int
+	nextMovementID    int64
+	nextAPIKeyID      int
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:        make(map[int]models.User),
+		products:     make(map[int]models.Product),
+		prices:       make(map[int]map[string]int64),
+		categories:   make(map[int]models.Category),
+		links:        make(map[int]map[int]bool),
+		orders:       make(map[int]models.Order),
+		reservations: make(map[int]models.Reservation),
+		apiKeys:      make(map[int]models.APIKey),
+	}
+}
+
+// tx records how to undo each change so Rollback can restore the store.
+type tx struct {
+	store *Store
+	undo  []func()
+	done  bool
+}
+
+func (s *Store) begin() (repository.Tx, error) {
+	s.txMu.Lock()
+	return &tx{store: s}, nil
+}
+
+func (t *tx) Commit() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	t.undo = nil
+	t.store.txMu.Unlock()
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+
+	t.store.mu.Lock()
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		t.undo[i]()
+	}
+	t.store.mu.Unlock()
+
+	t.undo = nil
+	t.store.txMu.Unlock()
+	return nil
+}
+
+// write runs fn under the data lock and, inside a transaction, keeps the undo
+// function it returns. Must be called with txMu held.
+func (s *Store) write(t repository.Tx, fn func() (undo func())) {
+	s.mu.Lock()
+	undo := fn()
+	s.mu.Unlock()
+
+	if t != nil && undo != nil {
+		mt := t.(*tx)
+		mt.undo = append(mt.undo, undo)
+	}
+}
+
+// now mirrors the microsecond precision of Postgres timestamps.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// reserved sums the active, unexpired reservations of a product. Must be
+// called with mu held.
+func (s *Store) reserved(productID int) int {
+	total := 0
+	at := time.Now()
+	for _, r := range s.reservations {
+		if r.ProductID == productID && r.Status == models.ReservationStatusActive && r.ExpiresAt.After(at) {
+			total += r.Quantity
+		}
+	}
+	return total
+}
+
+// withAvailability fills the computed stock fields. Must be called with mu
+// held.
+func (s *Store) withAvailability(p models.Product) models.Product {
+	p.Reserved = s.reserved(p.ID)
+	p.Available = p.Stock - p.Reserved
+	return p
+}
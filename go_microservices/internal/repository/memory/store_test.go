@@ -0,0 +1,254 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+// The memory backend stands in for Postgres in tests, so these tests pin down
+// the constraint and row semantics the services rely on.
+
+func createProduct(t *testing.T, repo *ProductRepository, stock int) *models.Product {
+	t.Helper()
+
+	tx, err := repo.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := &models.Product{Name: "Widget", Price: models.Money{Amount: 100, Currency: "USD"}, Stock: stock}
+	if err := repo.CreateTx(tx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
+
+func TestUserRepositoryCreateRejectsDuplicateEmail(t *testing.T) {
+	repo := NewUserRepository(NewStore())
+
+	if err := repo.Create(&models.User{Name: "Ann", Email: "ann@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	err := repo.Create(&models.User{Name: "Other Ann", Email: "ann@example.com"})
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("Create with a taken email: got %v, want %v", err, repository.ErrDuplicate)
+	}
+
+	bob := &models.User{Name: "Bob", Email: "bob@example.com"}
+	if err := repo.Create(bob); err != nil {
+		t.Fatal(err)
+	}
+	err = repo.Update(bob.ID, &models.User{Name: "Bob", Email: "ann@example.com"}, 0)
+	if !errors.Is(err, repository.ErrDuplicate) {
+		t.Fatalf("Update to a taken email: got %v, want %v", err, repository.ErrDuplicate)
+	}
+}
+
+func TestUserRepositoryVersionsAndTimestamps(t *testing.T) {
+	repo := NewUserRepository(NewStore())
+
+	user := &models.User{Name: "Ann", Email: "ann@example.com"}
+	if err := repo.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.Version != 1 || user.Role != models.RoleCustomer {
+		t.Fatalf("created user: got id %d, version %d, role %q", user.ID, user.Version, user.Role)
+	}
+	if user.CreatedAt.IsZero() || !user.UpdatedAt.Equal(user.CreatedAt) {
+		t.Fatalf("created user: created_at %v, updated_at %v", user.CreatedAt, user.UpdatedAt)
+	}
+	if user.CreatedAt.Nanosecond()%int(time.Microsecond) != 0 {
+		t.Fatalf("created_at %v has more than microsecond precision", user.CreatedAt)
+	}
+
+	time.Sleep(time.Millisecond)
+	if err := repo.Update(user.ID, &models.User{Name: "Anna", Email: "ann@example.com"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repo.GetByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != 2 || updated.Name != "Anna" {
+		t.Fatalf("updated user: got version %d, name %q", updated.Version, updated.Name)
+	}
+	if !updated.CreatedAt.Equal(user.CreatedAt) || !updated.UpdatedAt.After(user.UpdatedAt) {
+		t.Fatalf("updated user: created_at %v -> %v, updated_at %v -> %v",
+			user.CreatedAt, updated.CreatedAt, user.UpdatedAt, updated.UpdatedAt)
+	}
+
+	if err := repo.Update(user.ID, &models.User{Name: "Ann", Email: "ann@example.com"}, 1); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("Update with a stale version: got %v, want %v", err, repository.ErrVersionConflict)
+	}
+}
+
+func TestDeleteMissingRowReturnsErrNoRows(t *testing.T) {
+	store := NewStore()
+	users := NewUserRepository(store)
+	products := NewProductRepository(store)
+
+	if err := users.Delete(42, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("users.Delete: got %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := products.Delete(42, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("products.Delete: got %v, want %v", err, sql.ErrNoRows)
+	}
+
+	product := createProduct(t, products, 1)
+	if err := products.Delete(product.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.Delete(product.ID, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("deleting twice: got %v, want %v", err, sql.ErrNoRows)
+	}
+	if got, err := products.GetByID(product.ID); err != nil || got != nil {
+		t.Fatalf("GetByID after delete: got %v, %v", got, err)
+	}
+	if got, err := products.GetByIDWithDeleted(product.ID); err != nil || got == nil || got.DeletedAt == nil {
+		t.Fatalf("GetByIDWithDeleted after delete: got %v, %v", got, err)
+	}
+}
+
+func TestProductRepositoryUpdateStockTxIsConditional(t *testing.T) {
+	store := NewStore()
+	repo := NewProductRepository(store)
+	product := createProduct(t, repo, 5)
+
+	tx, err := repo.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	stock, err := repo.UpdateStockTx(tx, product.ID, 3)
+	if err != nil || stock != 2 {
+		t.Fatalf("UpdateStockTx(3) of 5: got %d, %v", stock, err)
+	}
+	if _, err := repo.UpdateStockTx(tx, product.ID, 3); err == nil {
+		t.Fatal("UpdateStockTx(3) of 2 succeeded")
+	}
+	if _, err := repo.UpdateStockTx(tx, 42, 1); err == nil {
+		t.Fatal("UpdateStockTx of a missing product succeeded")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.GetByID(product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 2 || got.Version != 2 {
+		t.Fatalf("after one successful decrement: got stock %d, version %d", got.Stock, got.Version)
+	}
+}
+
+func TestProductRepositoryUpdateStockTxKeepsReservedStock(t *testing.T) {
+	store := NewStore()
+	repo := NewProductRepository(store)
+	product := createProduct(t, repo, 5)
+	store.reservations[1] = models.Reservation{
+		ID:        1,
+		ProductID: product.ID,
+		Quantity:  4,
+		Status:    models.ReservationStatusActive,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	tx, err := repo.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := repo.UpdateStockTx(tx, product.ID, 2); err == nil {
+		t.Fatal("UpdateStockTx(2) with 1 available succeeded")
+	}
+	if stock, err := repo.UpdateStockTx(tx, product.ID, 1); err != nil || stock != 4 {
+		t.Fatalf("UpdateStockTx(1) with 1 available: got %d, %v", stock, err)
+	}
+}
+
+func TestRollbackUndoesWrites(t *testing.T) {
+	repo := NewProductRepository(NewStore())
+	product := createProduct(t, repo, 5)
+
+	tx, err := repo.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateStockTx(tx, product.ID, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateTx(tx, &models.Product{Name: "Gadget"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); !errors.Is(err, sql.ErrTxDone) {
+		t.Fatalf("Commit after Rollback: got %v, want %v", err, sql.ErrTxDone)
+	}
+
+	got, err := repo.GetByID(product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 5 || got.Version != 1 {
+		t.Fatalf("after rollback: got stock %d, version %d", got.Stock, got.Version)
+	}
+	if count, err := repo.Count(models.ProductFilter{}); err != nil || count != 1 {
+		t.Fatalf("Count after rollback: got %d, %v", count, err)
+	}
+}
+
+func TestProductRepositoryPurgeKeepsLedger(t *testing.T) {
+	store := NewStore()
+	repo := NewProductRepository(store)
+	movements := NewStockMovementRepository(store)
+	withHistory := createProduct(t, repo, 5)
+	withoutHistory := createProduct(t, repo, 0)
+
+	tx, err := repo.BeginTx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := movements.CreateTx(tx, &models.StockMovement{
+		ProductID:      withHistory.ID,
+		Reason:         models.StockReasonInitial,
+		Delta:          5,
+		ResultingStock: 5,
+		Actor:          "test",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*models.Product{withHistory, withoutHistory} {
+		if err := repo.Delete(p.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	purged, kept, err := repo.Purge(time.Now().Add(time.Second))
+	if err != nil || purged != 1 || kept != 1 {
+		t.Fatalf("Purge: got %d purged, %d kept, %v", purged, kept, err)
+	}
+	if got, _ := repo.GetByIDWithDeleted(withoutHistory.ID); got != nil {
+		t.Fatal("the product without stock movements was kept")
+	}
+	if got, _ := repo.GetByIDWithDeleted(withHistory.ID); got == nil {
+		t.Fatal("the product with stock movements was purged")
+	}
+	if count, err := movements.CountByProductID(withHistory.ID); err != nil || count != 1 {
+		t.Fatalf("stock movements after purge: got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,287 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type UserRepository struct {
+	store *Store
+}
+
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+func (r *UserRepository) Create(user *models.User) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+
+	if user.Role == "" {
+		user.Role = models.RoleCustomer
+	}
+	r.store.nextUserID++
+	user.ID = r.store.nextUserID
+	user.Version = 1
+	user.CreatedAt = now()
+	user.UpdatedAt = user.CreatedAt
+	r.store.users[user.ID] = *user
+	return nil
+}
+
+func (r *UserRepository) GetByID(id int) (*models.User, error) {
+	user, err := r.GetByIDWithDeleted(id)
+	if user == nil || user.DeletedAt != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetByIDWithDeleted also finds soft-deleted users.
+func (r *UserRepository) GetByIDWithDeleted(id int) (*models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// GetByEmail also finds soft-deleted users: an email stays taken until the
+// user is purged.
+func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, user := range r.store.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) GetAll(limit, offset int, includeDeleted bool) ([]models.User, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return paginate(r.sorted(includeDeleted), limit, offset), nil
+}
+
+func (r *UserRepository) GetPage(cursor *models.Cursor, limit int, includeDeleted bool) ([]models.User, bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	users := r.sorted(includeDeleted)
+	if cursor == nil {
+		return firstN(users, limit)
+	}
+	if cursor.Backward {
+		i := sort.Search(len(users), func(i int) bool { return users[i].ID >= cursor.ID })
+		return lastN(users[:i], limit)
+	}
+	i := sort.Search(len(users), func(i int) bool { return users[i].ID > cursor.ID })
+	return firstN(users[i:], limit)
+}
+
+// Export calls fn for every user matching filter, in id order, until ctx
+// ends. It works on a snapshot taken up front, so fn may take as long as it
+// needs.
+func (r *UserRepository) Export(ctx context.Context, filter models.ExportFilter, fn func(models.User) error) error {
+	r.store.mu.RLock()
+	users := r.sorted(filter.IncludeDeleted)
+	r.store.mu.RUnlock()
+
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if filter.UpdatedSince != nil && user.UpdatedAt.Before(*filter.UpdatedSince) {
+			continue
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update replaces the editable fields of the user. A non-zero version makes
+// the write conditional on the row still being at that version.
+func (r *UserRepository) Update(id int, user *models.User, version int) error {
+	updated, err := r.update(id, version, func(u *models.User) {
+		u.Name = user.Name
+		u.Email = user.Email
+	})
+	if err != nil {
+		return err
+	}
+
+	user.Version = updated.Version
+	user.UpdatedAt = updated.UpdatedAt
+	return nil
+}
+
+// Patch updates only the fields present in patch, under the same version
+// rule as Update.
+func (r *UserRepository) Patch(id int, patch *models.UserPatch, version int) error {
+	_, err := r.update(id, version, func(u *models.User) {
+		if patch.Name.Set {
+			u.Name = patch.Name.Value
+		}
+		if patch.Email.Set {
+			u.Email = patch.Email.Value
+		}
+	})
+	return err
+}
+
+func (r *UserRepository) update(id, version int, apply func(u *models.User)) (models.User, error) {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok || user.DeletedAt != nil {
+		return models.User{}, sql.ErrNoRows
+	}
+	if version != 0 && user.Version != version {
+		return models.User{}, repository.ErrVersionConflict
+	}
+
+	apply(&user)
+	if r.emailTaken(user.Email, id) {
+		return models.User{}, ErrDuplicateEmail
+	}
+	user.Version++
+	user.UpdatedAt = now()
+	r.store.users[id] = user
+	return user, nil
+}
+
+// SetRole changes the role of the user under the same version rule as Update.
+func (r *UserRepository) SetRole(id int, role string, version int) error {
+	_, err := r.update(id, version, func(u *models.User) {
+		u.Role = role
+	})
+	return err
+}
+
+// SetPasswordHash replaces the password hash of the user if it is still
+// oldHash, leaving the version and updated_at alone. It returns sql.ErrNoRows
+// if the hash has changed.
+func (r *UserRepository) SetPasswordHash(id int, oldHash, newHash string) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok || user.PasswordHash != oldHash {
+		return sql.ErrNoRows
+	}
+	user.PasswordHash = newHash
+	r.store.users[id] = user
+	return nil
+}
+
+// Delete soft-deletes the user under the same version rule as Update.
+func (r *UserRepository) Delete(id, version int) error {
+	_, err := r.update(id, version, func(u *models.User) {
+		at := now()
+		u.DeletedAt = &at
+	})
+	return err
+}
+
+// Restore undoes a soft delete. It returns sql.ErrNoRows unless the user
+// exists and is deleted.
+func (r *UserRepository) Restore(id int) error {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	user, ok := r.store.users[id]
+	if !ok || user.DeletedAt == nil {
+		return sql.ErrNoRows
+	}
+
+	user.DeletedAt = nil
+	user.Version++
+	user.UpdatedAt = now()
+	r.store.users[id] = user
+	return nil
+}
+
+// Purge hard-deletes users soft-deleted before the given time. Users that
+// still have orders are kept so order history stays intact; kept is how many
+// of them were due.
+func (r *UserRepository) Purge(before time.Time) (purged, kept int64, err error) {
+	r.store.txMu.Lock()
+	defer r.store.txMu.Unlock()
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	ordered := make(map[int]bool)
+	for _, order := range r.store.orders {
+		ordered[order.UserID] = true
+	}
+
+	for id, user := range r.store.users {
+		if user.DeletedAt == nil || !user.DeletedAt.Before(before) {
+			continue
+		}
+		if ordered[id] {
+			kept++
+			continue
+		}
+		delete(r.store.users, id)
+		purged++
+	}
+	return purged, kept, nil
+}
+
+func (r *UserRepository) Count(includeDeleted bool) (int, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return len(r.sorted(includeDeleted)), nil
+}
+
+// sorted returns the users ordered by id, hiding soft-deleted ones unless
+// includeDeleted is set. Must be called with mu held.
+func (r *UserRepository) sorted(includeDeleted bool) []models.User {
+	users := make([]models.User, 0, len(r.store.users))
+	for _, user := range r.store.users {
+		if includeDeleted || user.DeletedAt == nil {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
+}
+
+// emailTaken must be called with mu held.
+func (r *UserRepository) emailTaken(email string, exceptID int) bool {
+	for _, user := range r.store.users {
+		if user.Email == email && user.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
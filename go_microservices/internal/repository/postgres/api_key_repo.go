@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go_microservices/internal/models"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	query := `
+        INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NOW())
+        RETURNING id, created_at
+    `
+
+	err := r.db.QueryRow(query,
+		key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.CreatedBy, key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	return constraintError(err)
+}
+
+func (r *APIKeyRepository) GetByID(id int) (*models.APIKey, error) {
+	return r.getOne("id = $1", id)
+}
+
+// GetByHash finds a key by the hash of the key, including revoked and expired
+// keys.
+func (r *APIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	return r.getOne("key_hash = $1", keyHash)
+}
+
+func (r *APIKeyRepository) getOne(where string, arg interface{}) (*models.APIKey, error) {
+	query := `
+        SELECT id, name, prefix, key_hash, scopes, created_by, created_at, expires_at, last_used_at, revoked_at
+        FROM api_keys
+        WHERE ` + where + `
+    `
+
+	key, err := scanAPIKey(r.db.QueryRow(query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+// GetAll returns every key, revoked ones included, newest first.
+func (r *APIKeyRepository) GetAll() ([]models.APIKey, error) {
+	query := `
+        SELECT id, name, prefix, key_hash, scopes, created_by, created_at, expires_at, last_used_at, revoked_at
+        FROM api_keys
+        ORDER BY id DESC
+    `
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke marks the key as revoked. Revoking a revoked key keeps the original
+// time. It returns sql.ErrNoRows if there is no such key.
+func (r *APIKeyRepository) Revoke(id int) error {
+	query := `
+        UPDATE api_keys
+        SET revoked_at = COALESCE(revoked_at, NOW())
+        WHERE id = $1
+    `
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(id int, at time.Time) error {
+	_, err := r.db.Exec("UPDATE api_keys SET last_used_at = $1 WHERE id = $2", at, id)
+	return err
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes), &key.CreatedBy,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func (r *CategoryRepository) BeginTx() (repository.Tx, error) {
+	return beginTx(r.db)
+}
+
+// LockTx serializes changes to the tree until the transaction ends. Reads are
+// not blocked.
+func (r *CategoryRepository) LockTx(tx repository.Tx) error {
+	_, err := sqlTx(tx).Exec("LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE")
+	return err
+}
+
+func (r *CategoryRepository) CreateTx(tx repository.Tx, category *models.Category) error {
+	query := `
+        INSERT INTO categories (parent_id, name, created_at, updated_at)
+        VALUES ($1, $2, NOW(), NOW())
+        RETURNING id, created_at, updated_at
+    `
+
+	err := sqlTx(tx).QueryRow(query, category.ParentID, category.Name).Scan(
+		&category.ID, &category.CreatedAt, &category.UpdatedAt,
+	)
+	return constraintError(err)
+}
+
+// GetAll returns every category ordered by name, parents and children alike.
+func (r *CategoryRepository) GetAll() ([]models.Category, error) {
+	query := `
+        SELECT id, parent_id, name, created_at, updated_at
+        FROM categories
+        ORDER BY name, id
+    `
+
+	return r.query(r.db, query)
+}
+
+// GetPathTx returns id followed by the IDs of its ancestors up to the root,
+// or nothing if there is no such category.
+func (r *CategoryRepository) GetPathTx(tx repository.Tx, id int) ([]int, error) {
+	query := `
+        WITH RECURSIVE path AS (
+            SELECT id, parent_id, 0 AS depth
+            FROM categories
+            WHERE id = $1
+            UNION ALL
+            SELECT c.id, c.parent_id, p.depth + 1
+            FROM categories c
+            JOIN path p ON c.id = p.parent_id
+        )
+        SELECT id FROM path ORDER BY depth
+    `
+
+	rows, err := sqlTx(tx).Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var path []int
+	for rows.Next() {
+		var ancestor int
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, err
+		}
+		path = append(path, ancestor)
+	}
+	return path, rows.Err()
+}
+
+// CountTx counts how many of ids are existing categories.
+func (r *CategoryRepository) CountTx(tx repository.Tx, ids []int) (int, error) {
+	var count int
+	err := sqlTx(tx).QueryRow(
+		"SELECT COUNT(*) FROM categories WHERE id = ANY($1)", pq.Array(int64s(ids)),
+	).Scan(&count)
+	return count, err
+}
+
+func (r *CategoryRepository) HasChildrenTx(tx repository.Tx, id int) (bool, error) {
+	var exists bool
+	err := sqlTx(tx).QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)", id,
+	).Scan(&exists)
+	return exists, err
+}
+
+// UpdateTx renames and moves a category. It returns sql.ErrNoRows if there is
+// no such category.
+func (r *CategoryRepository) UpdateTx(tx repository.Tx, category *models.Category) error {
+	query := `
+        UPDATE categories
+        SET parent_id = $1,
+            name = $2,
+            updated_at = NOW()
+        WHERE id = $3
+        RETURNING created_at, updated_at
+    `
+
+	err := sqlTx(tx).QueryRow(query, category.ParentID, category.Name, category.ID).Scan(
+		&category.CreatedAt, &category.UpdatedAt,
+	)
+	return constraintError(err)
+}
+
+// DeleteTx removes a category without subcategories; its product links go
+// with it. It returns sql.ErrNoRows if there is no such category.
+func (r *CategoryRepository) DeleteTx(tx repository.Tx, id int) error {
+	result, err := sqlTx(tx).Exec("DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetByProductID returns the categories a product is linked to, ordered by
+// name. It returns sql.ErrNoRows if there is no such product.
+func (r *CategoryRepository) GetByProductID(productID int) ([]models.Category, error) {
+	query := `
+        SELECT c.id, c.parent_id, c.name, c.created_at, c.updated_at
+        FROM categories c
+        JOIN product_categories pc ON pc.category_id = c.id
+        WHERE pc.product_id = $1
+        ORDER BY c.name, c.id
+    `
+
+	categories, err := r.query(r.db, query, productID)
+	if err != nil || len(categories) > 0 {
+		return categories, err
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+	return categories, nil
+}
+
+// SetProductCategoriesTx replaces the categories of a product. It returns
+// sql.ErrNoRows if there is no such product.
+func (r *CategoryRepository) SetProductCategoriesTx(tx repository.Tx, productID int, categoryIDs []int) error {
+	q := sqlTx(tx)
+
+	var id int
+	err := q.QueryRow(
+		"SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", productID,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.Exec("DELETE FROM product_categories WHERE product_id = $1", productID); err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO product_categories (product_id, category_id)
+        SELECT $1, unnest($2::int[])
+    `
+
+	_, err = q.Exec(query, productID, pq.Array(int64s(categoryIDs)))
+	return constraintError(err)
+}
+
+func (r *CategoryRepository) query(q querier, query string, args ...interface{}) ([]models.Category, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Name, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// categorySubtree selects the ID of the category bound to $n and the IDs of
+// all its descendants.
+func categorySubtree(n int) string {
+	return fmt.Sprintf(`
+            WITH RECURSIVE subtree AS (
+                SELECT id FROM categories WHERE id = $%d
+                UNION ALL
+                SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+            )
+            SELECT id FROM subtree`, n)
+}
+
+func int64s(ids []int) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type OrderRepository struct {
+	db *sql.DB
+}
+
+func NewOrderRepository(db *sql.DB) *OrderRepository {
+	return &OrderRepository{db: db}
+}
+
+func (r *OrderRepository) BeginTx() (repository.Tx, error) {
+	return beginTx(r.db)
+}
+
+// CreateTx inserts the order and its items inside the caller's transaction.
+func (r *OrderRepository) CreateTx(tx repository.Tx, order *models.Order) error {
+	query := `
+        INSERT INTO orders (user_id, status, total_minor, currency, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, NOW(), NOW())
+        RETURNING id, created_at, updated_at
+    `
+
+	err := sqlTx(tx).QueryRow(
+		query, order.UserID, order.Status, order.Total.Amount, order.Total.Currency,
+	).Scan(
+		&order.ID, &order.CreatedAt, &order.UpdatedAt,
+	)
+	if err != nil {
+		return constraintError(err)
+	}
+
+	itemQuery := `
+        INSERT INTO order_items (order_id, product_id, quantity, unit_price_minor)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id
+    `
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.OrderID = order.ID
+		if err := sqlTx(tx).QueryRow(
+			itemQuery, item.OrderID, item.ProductID, item.Quantity, item.UnitPrice.Amount,
+		).Scan(&item.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *OrderRepository) GetByID(id int) (*models.Order, error) {
+	query := `
+        SELECT id, user_id, status, total_minor, currency, created_at, updated_at
+        FROM orders
+        WHERE id = $1
+    `
+
+	var order models.Order
+	err := r.db.QueryRow(query, id).Scan(
+		&order.ID, &order.UserID, &order.Status, &order.Total.Amount, &order.Total.Currency,
+		&order.CreatedAt, &order.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order.Items, err = r.getItems(id, order.Total.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *OrderRepository) GetByUserID(userID, limit, offset int) ([]models.Order, error) {
+	query := `
+        SELECT id, user_id, status, total_minor, currency, created_at, updated_at
+        FROM orders
+        WHERE user_id = $1
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3
+    `
+
+	rows, err := r.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var o models.Order
+		if err := rows.Scan(
+			&o.ID, &o.UserID, &o.Status, &o.Total.Amount, &o.Total.Currency, &o.CreatedAt, &o.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		orders[i].Items, err = r.getItems(orders[i].ID, orders[i].Total.Currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if orders == nil {
+		return []models.Order{}, nil
+	}
+	return orders, nil
+}
+
+func (r *OrderRepository) CountByUserID(userID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM orders WHERE user_id = $1", userID).Scan(&count)
+	return count, err
+}
+
+// getItems loads the items of an order. Unit prices are in the currency of
+// the order.
+func (r *OrderRepository) getItems(orderID int, currency string) ([]models.OrderItem, error) {
+	query := `
+        SELECT id, order_id, product_id, quantity, unit_price_minor
+        FROM order_items
+        WHERE order_id = $1
+        ORDER BY id
+    `
+
+	rows, err := r.db.Query(query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.OrderItem{}
+	for rows.Next() {
+		item := models.OrderItem{UnitPrice: models.Money{Currency: currency}}
+		if err := rows.Scan(
+			&item.ID, &item.OrderID, &item.ProductID, &item.Quantity, &item.UnitPrice.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -1,12 +1,27 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 
 	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
 )
 
+// reservedColumn sums the active, unexpired reservations of a product row.
+const reservedColumn = `(
+            SELECT COALESCE(SUM(quantity), 0)
+            FROM reservations
+            WHERE product_id = products.id
+              AND status = 'active'
+              AND expires_at > NOW()
+        )`
+
 type ProductRepository struct {
 	db *sql.DB
 }
@@ -15,45 +30,106 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
-func (r *ProductRepository) Create(product *models.Product) error {
+func (r *ProductRepository) BeginTx() (repository.Tx, error) {
+	return beginTx(r.db)
+}
+
+func (r *ProductRepository) CreateTx(tx repository.Tx, product *models.Product) error {
 	query := `
-        INSERT INTO products (name, description, price, stock, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, NOW(), NOW())
-        RETURNING id, created_at, updated_at
+        INSERT INTO products (name, description, price_minor, currency, stock, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+        RETURNING id, version, created_at, updated_at
     `
 
-	return r.db.QueryRow(
-		query, product.Name, product.Description, product.Price, product.Stock,
-	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	return sqlTx(tx).QueryRow(
+		query, product.Name, product.Description, product.Price.Amount, product.Price.Currency, product.Stock,
+	).Scan(&product.ID, &product.Version, &product.CreatedAt, &product.UpdatedAt)
+}
+
+// CreateManyTx inserts products with multi-row INSERTs of up to
+// insertBatchSize rows and fills in their IDs and timestamps. Postgres returns
+// the rows of a VALUES list in the order they were given.
+func (r *ProductRepository) CreateManyTx(tx repository.Tx, products []*models.Product) error {
+	for start := 0; start < len(products); start += insertBatchSize {
+		batch := products[start:min(start+insertBatchSize, len(products))]
+
+		args := make([]interface{}, 0, len(batch)*5)
+		for _, p := range batch {
+			args = append(args, p.Name, p.Description, p.Price.Amount, p.Price.Currency, p.Stock)
+		}
+
+		query := `
+        INSERT INTO products (name, description, price_minor, currency, stock)
+        VALUES ` + valuesList(len(batch), 5) + `
+        RETURNING id, version, created_at, updated_at
+    `
+
+		rows, err := sqlTx(tx).Query(query, args...)
+		if err != nil {
+			return err
+		}
+
+		i := 0
+		for rows.Next() {
+			p := batch[i]
+			if err := rows.Scan(&p.ID, &p.Version, &p.CreatedAt, &p.UpdatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			i++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *ProductRepository) GetByID(id int) (*models.Product, error) {
+	return r.getByID(id, "AND deleted_at IS NULL")
+}
+
+// GetByIDWithDeleted also finds soft-deleted products.
+func (r *ProductRepository) GetByIDWithDeleted(id int) (*models.Product, error) {
+	return r.getByID(id, "")
+}
+
+func (r *ProductRepository) getByID(id int, scope string) (*models.Product, error) {
 	query := `
-        SELECT id, name, description, price, stock, created_at, updated_at
+        SELECT id, name, description, price_minor, currency, stock, ` + reservedColumn + `,
+               version, created_at, updated_at, deleted_at
         FROM products
-        WHERE id = $1
+        WHERE id = $1 ` + scope + `
     `
 
 	var product models.Product
 	err := r.db.QueryRow(query, id).Scan(
 		&product.ID, &product.Name, &product.Description,
-		&product.Price, &product.Stock, &product.CreatedAt, &product.UpdatedAt,
+		&product.Price.Amount, &product.Price.Currency, &product.Stock, &product.Reserved,
+		&product.Version, &product.CreatedAt, &product.UpdatedAt, &product.DeletedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
+	product.Available = product.Stock - product.Reserved
 	return &product, err
 }
 
-func (r *ProductRepository) GetAll(limit, offset int) ([]models.Product, error) {
-	query := `
-        SELECT id, name, description, price, stock, created_at, updated_at
+func (r *ProductRepository) GetAll(filter models.ProductFilter, limit, offset int) ([]models.Product, error) {
+	where, args := productFilterClause(filter)
+	args = append(args, limit, offset)
+
+	query := fmt.Sprintf(`
+        SELECT id, name, description, price_minor, currency, stock, `+reservedColumn+`,
+               version, created_at, updated_at, deleted_at
         FROM products
-        ORDER BY id
-        LIMIT $1 OFFSET $2
-    `
+        %s
+        ORDER BY %s
+        LIMIT $%d OFFSET $%d
+    `, where, productOrderClause(filter), len(args)-1, len(args))
 
-	rows, err := r.db.Query(query, limit, offset)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -63,11 +139,12 @@ func (r *ProductRepository) GetAll(limit, offset int) ([]models.Product, error)
 	for rows.Next() {
 		var p models.Product
 		if err := rows.Scan(
-			&p.ID, &p.Name, &p.Description, &p.Price,
-			&p.Stock, &p.CreatedAt, &p.UpdatedAt,
+			&p.ID, &p.Name, &p.Description, &p.Price.Amount, &p.Price.Currency,
+			&p.Stock, &p.Reserved, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
+		p.Available = p.Stock - p.Reserved
 		products = append(products, p)
 	}
 
@@ -77,57 +154,423 @@ func (r *ProductRepository) GetAll(limit, offset int) ([]models.Product, error)
 	return products, nil
 }
 
-func (r *ProductRepository) Update(id int, product *models.Product) error {
+// GetPage returns up to limit products after (or, for a backward cursor,
+// before) the cursor position, in display order, and whether more rows exist
+// in the direction of travel.
+func (r *ProductRepository) GetPage(filter models.ProductFilter, cursor *models.Cursor, limit int) ([]models.Product, bool, error) {
+	where, args := productFilterClause(filter)
+
+	backward := cursor != nil && cursor.Backward
+	order := filter
+	if backward {
+		order.Order = "desc"
+		if filter.Order == "desc" {
+			order.Order = "asc"
+		}
+	}
+
+	if cursor != nil {
+		op := ">"
+		if order.Order == "desc" {
+			op = "<"
+		}
+
+		var condition string
+		column, cast := productSortColumn(filter.Sort)
+		if column == "id" {
+			args = append(args, cursor.ID)
+			condition = fmt.Sprintf("id %s $%d", op, len(args))
+		} else {
+			args = append(args, cursor.Value, cursor.ID)
+			condition = fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)", column, op, len(args)-1, cast, len(args))
+		}
+
+		if where == "" {
+			where = "WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+	}
+
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`
+        SELECT id, name, description, price_minor, currency, stock, `+reservedColumn+`,
+               version, created_at, updated_at, deleted_at
+        FROM products
+        %s
+        ORDER BY %s
+        LIMIT $%d
+    `, where, productOrderClause(order), len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Description, &p.Price.Amount, &p.Price.Currency,
+			&p.Stock, &p.Reserved, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
+		); err != nil {
+			return nil, false, err
+		}
+		p.Available = p.Stock - p.Reserved
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(products) > limit
+	if hasMore {
+		products = products[:limit]
+	}
+	if backward {
+		for i, j := 0, len(products)-1; i < j; i, j = i+1, j-1 {
+			products[i], products[j] = products[j], products[i]
+		}
+	}
+	return products, hasMore, nil
+}
+
+// Export calls fn for every product matching filter, in id order, streaming
+// them from a server-side cursor.
+func (r *ProductRepository) Export(ctx context.Context, filter models.ExportFilter, fn func(models.Product) error) error {
+	where, args := exportClause(filter)
+	query := `
+        SELECT id, name, description, price_minor, currency, stock, ` + reservedColumn + `,
+               version, created_at, updated_at, deleted_at
+        FROM products
+        ` + where + `
+        ORDER BY id
+    `
+
+	return streamRows(ctx, r.db, query, args, func(rows *sql.Rows) error {
+		var p models.Product
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Description, &p.Price.Amount, &p.Price.Currency,
+			&p.Stock, &p.Reserved, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
+		); err != nil {
+			return err
+		}
+		p.Available = p.Stock - p.Reserved
+		return fn(p)
+	})
+}
+
+// UpdateTx replaces the editable fields of the product. A non-zero version
+// makes the write conditional on the row still being at that version.
+func (r *ProductRepository) UpdateTx(tx repository.Tx, id int, product *models.Product, version int) error {
 	query := `
         UPDATE products
-        SET name = COALESCE($1, name),
-            description = COALESCE($2, description),
-            price = COALESCE($3, price),
-            stock = COALESCE($4, stock),
+        SET name = $1,
+            description = $2,
+            price_minor = $3,
+            currency = $4,
+            stock = $5,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $6 AND deleted_at IS NULL AND ($7 = 0 OR version = $7)
+        RETURNING version, updated_at
+    `
+
+	err := sqlTx(tx).QueryRow(
+		query, product.Name, product.Description, product.Price.Amount, product.Price.Currency,
+		product.Stock, id, version,
+	).Scan(&product.Version, &product.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return noRowsReason(sqlTx(tx), "products", id, version)
+	}
+	return err
+}
+
+// UpdateManyTx replaces the fields of several products in one statement. Each
+// product is matched by its ID and Version, which are then advanced like
+// UpdateTx does; ErrVersionConflict is returned if any of them no longer
+// matches.
+func (r *ProductRepository) UpdateManyTx(tx repository.Tx, products []*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(products))
+	versions := make([]int64, len(products))
+	names := make([]string, len(products))
+	descriptions := make([]string, len(products))
+	amounts := make([]int64, len(products))
+	currencies := make([]string, len(products))
+	stocks := make([]int64, len(products))
+	byID := make(map[int]*models.Product, len(products))
+	for i, p := range products {
+		ids[i], versions[i], stocks[i] = int64(p.ID), int64(p.Version), int64(p.Stock)
+		names[i], descriptions[i] = p.Name, p.Description
+		amounts[i], currencies[i] = p.Price.Amount, p.Price.Currency
+		byID[p.ID] = p
+	}
+
+	query := `
+        UPDATE products AS p
+        SET name = v.name,
+            description = v.description,
+            price_minor = v.price_minor,
+            currency = v.currency,
+            stock = v.stock,
+            version = p.version + 1,
             updated_at = NOW()
-        WHERE id = $5
-        RETURNING updated_at
+        FROM unnest($1::int[], $2::int[], $3::text[], $4::text[], $5::bigint[], $6::text[], $7::int[])
+             AS v(id, version, name, description, price_minor, currency, stock)
+        WHERE p.id = v.id AND p.version = v.version AND p.deleted_at IS NULL
+        RETURNING p.id, p.version, p.updated_at
     `
 
-	var name, description *string
-	var price *float64
-	var stock *int
+	rows, err := sqlTx(tx).Query(query,
+		pq.Array(ids), pq.Array(versions), pq.Array(names), pq.Array(descriptions),
+		pq.Array(amounts), pq.Array(currencies), pq.Array(stocks),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	updated := 0
+	for rows.Next() {
+		var id int
+		var p models.Product
+		if err := rows.Scan(&id, &p.Version, &p.UpdatedAt); err != nil {
+			return err
+		}
+		byID[id].Version, byID[id].UpdatedAt = p.Version, p.UpdatedAt
+		updated++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if updated != len(products) {
+		return repository.ErrVersionConflict
+	}
+	return nil
+}
 
-	if product.Name != "" {
-		name = &product.Name
+// PatchTx updates only the fields present in patch, under the same version
+// rule as UpdateTx. A null description is stored as empty.
+func (r *ProductRepository) PatchTx(tx repository.Tx, id int, patch *models.ProductPatch, version int) error {
+	var set setClause
+	if patch.Name.Set {
+		set.add("name", patch.Name.Value)
+	}
+	if patch.Description.Set {
+		set.add("description", patch.Description.Value)
 	}
-	if product.Description != "" {
-		description = &product.Description
+	if patch.Price.Set {
+		set.add("price_minor", patch.Price.Value.Amount)
+		set.add("currency", patch.Price.Value.Currency)
 	}
-	if product.Price > 0 {
-		price = &product.Price
+	if patch.Stock.Set {
+		set.add("stock", patch.Stock.Value)
 	}
-	if product.Stock >= 0 {
-		stock = &product.Stock
+
+	query, args := set.update("products", id, version)
+	result, err := sqlTx(tx).Exec(query, args...)
+	if err != nil {
+		return err
 	}
 
-	return r.db.QueryRow(query, name, description, price, stock, id).Scan(&product.UpdatedAt)
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return noRowsReason(sqlTx(tx), "products", id, version)
+	}
+	return nil
 }
 
-func (r *ProductRepository) UpdateStock(id, quantity int) error {
+// UpdateStockTx decrements stock as part of the caller's transaction and
+// returns the resulting stock.
+func (r *ProductRepository) UpdateStockTx(tx repository.Tx, id, quantity int) (int, error) {
 	query := `
         UPDATE products
         SET stock = stock - $1,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $2 AND stock >= $1
+        WHERE id = $2 AND deleted_at IS NULL AND stock - ` + reservedColumn + ` >= $1
         RETURNING stock
     `
 
 	var newStock int
-	err := r.db.QueryRow(query, quantity, id).Scan(&newStock)
+	err := sqlTx(tx).QueryRow(query, quantity, id).Scan(&newStock)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("insufficient stock or product not found")
+		return 0, fmt.Errorf("insufficient stock or product not found")
 	}
+	return newStock, err
+}
+
+// RestockTx increments stock as part of the caller's transaction and returns
+// the resulting stock.
+func (r *ProductRepository) RestockTx(tx repository.Tx, id, quantity int) (int, error) {
+	query := `
+        UPDATE products
+        SET stock = stock + $1,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $2 AND deleted_at IS NULL
+        RETURNING stock
+    `
+
+	var newStock int
+	err := sqlTx(tx).QueryRow(query, quantity, id).Scan(&newStock)
+	return newStock, err
+}
+
+// GetByIDForUpdate loads a product and locks its row until tx finishes.
+func (r *ProductRepository) GetByIDForUpdate(tx repository.Tx, id int) (*models.Product, error) {
+	query := `
+        SELECT id, name, description, price_minor, currency, stock, ` + reservedColumn + `,
+               version, created_at, updated_at, deleted_at
+        FROM products
+        WHERE id = $1 AND deleted_at IS NULL
+        FOR UPDATE
+    `
+
+	var product models.Product
+	err := sqlTx(tx).QueryRow(query, id).Scan(
+		&product.ID, &product.Name, &product.Description,
+		&product.Price.Amount, &product.Price.Currency, &product.Stock, &product.Reserved,
+		&product.Version, &product.CreatedAt, &product.UpdatedAt, &product.DeletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	product.Available = product.Stock - product.Reserved
+	return &product, err
+}
+
+// GetByNamesForUpdate loads the products with any of the given names and
+// locks their rows until tx finishes.
+func (r *ProductRepository) GetByNamesForUpdate(tx repository.Tx, names []string) ([]models.Product, error) {
+	query := `
+        SELECT id, name, description, price_minor, currency, stock, ` + reservedColumn + `,
+               version, created_at, updated_at, deleted_at
+        FROM products
+        WHERE name = ANY($1) AND deleted_at IS NULL
+        ORDER BY id
+        FOR UPDATE
+    `
+
+	rows, err := sqlTx(tx).Query(query, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var p models.Product
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Description, &p.Price.Amount, &p.Price.Currency,
+			&p.Stock, &p.Reserved, &p.Version, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		p.Available = p.Stock - p.Reserved
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// GetPrices returns the price list of a product: its prices in currencies
+// other than the base one, ordered by currency.
+func (r *ProductRepository) GetPrices(productID int) ([]models.Money, error) {
+	query := `
+        SELECT amount_minor, currency
+        FROM product_prices
+        WHERE product_id = $1
+        ORDER BY currency
+    `
+
+	rows, err := r.db.Query(query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []models.Money
+	for rows.Next() {
+		var price models.Money
+		if err := rows.Scan(&price.Amount, &price.Currency); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
+
+// SetPriceTx adds price to the price list of a product or replaces the entry
+// in the same currency.
+func (r *ProductRepository) SetPriceTx(tx repository.Tx, productID int, price models.Money) error {
+	query := `
+        INSERT INTO product_prices (product_id, currency, amount_minor, updated_at)
+        VALUES ($1, $2, $3, NOW())
+        ON CONFLICT (product_id, currency)
+        DO UPDATE SET amount_minor = EXCLUDED.amount_minor, updated_at = NOW()
+    `
+
+	_, err := sqlTx(tx).Exec(query, productID, price.Currency, price.Amount)
 	return err
 }
 
-func (r *ProductRepository) Delete(id int) error {
-	result, err := r.db.Exec("DELETE FROM products WHERE id = $1", id)
+// DeletePriceTx removes the entry in currency from the price list of a
+// product. It returns sql.ErrNoRows if there is none.
+func (r *ProductRepository) DeletePriceTx(tx repository.Tx, productID int, currency string) error {
+	result, err := sqlTx(tx).Exec(
+		"DELETE FROM product_prices WHERE product_id = $1 AND currency = $2", productID, currency,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete soft-deletes the product under the same version rule as UpdateTx.
+// Its reservations and stock history are kept.
+func (r *ProductRepository) Delete(id, version int) error {
+	query := `
+        UPDATE products
+        SET deleted_at = NOW(),
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+    `
+
+	result, err := r.db.Exec(query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return noRowsReason(r.db, "products", id, version)
+	}
+	return nil
+}
+
+// Restore undoes a soft delete. It returns sql.ErrNoRows unless the product
+// exists and is deleted.
+func (r *ProductRepository) Restore(id int) error {
+	query := `
+        UPDATE products
+        SET deleted_at = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NOT NULL
+    `
+
+	result, err := r.db.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -139,8 +582,106 @@ func (r *ProductRepository) Delete(id int) error {
 	return nil
 }
 
-func (r *ProductRepository) Count() (int, error) {
+// Purge hard-deletes products soft-deleted before the given time, together
+// with their reservations. Products referenced by orders or by the
+// append-only stock ledger are kept; kept is how many of them were due.
+func (r *ProductRepository) Purge(before time.Time) (purged, kept int64, err error) {
+	// As in UserRepository.Purge, the outer SELECT counts the expired
+	// products as they were before the DELETE.
+	query := `
+        WITH purged AS (
+            DELETE FROM products
+            WHERE deleted_at < $1
+              AND NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.product_id = products.id)
+              AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE stock_movements.product_id = products.id)
+            RETURNING id
+        )
+        SELECT (SELECT COUNT(*) FROM purged),
+               (SELECT COUNT(*) FROM products WHERE deleted_at < $1) - (SELECT COUNT(*) FROM purged)
+    `
+
+	err = r.db.QueryRow(query, before).Scan(&purged, &kept)
+	return purged, kept, err
+}
+
+func (r *ProductRepository) Count(filter models.ProductFilter) (int, error) {
+	where, args := productFilterClause(filter)
+
 	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM products").Scan(&count)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM products "+where, args...).Scan(&count)
 	return count, err
 }
+
+// productFilterClause builds a WHERE clause and its positional arguments.
+func productFilterClause(filter models.ProductFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if filter.Query != "" {
+		args = append(args, filter.Query)
+		conditions = append(conditions, fmt.Sprintf("search_vector @@ websearch_to_tsquery('simple', $%d)", len(args)))
+	}
+	if filter.MinPrice != nil {
+		args = append(args, *filter.MinPrice)
+		conditions = append(conditions, fmt.Sprintf("price_minor >= $%d", len(args)))
+	}
+	if filter.MaxPrice != nil {
+		args = append(args, *filter.MaxPrice)
+		conditions = append(conditions, fmt.Sprintf("price_minor <= $%d", len(args)))
+	}
+	if filter.InStock != nil {
+		if *filter.InStock {
+			conditions = append(conditions, "stock - "+reservedColumn+" > 0")
+		} else {
+			conditions = append(conditions, "stock - "+reservedColumn+" <= 0")
+		}
+	}
+	if filter.Category != nil {
+		args = append(args, *filter.Category)
+		conditions = append(conditions, `id IN (
+            SELECT product_id
+            FROM product_categories
+            WHERE category_id IN (`+categorySubtree(len(args))+`)
+        )`)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// productSortColumn maps the whitelisted sort field to its column and the SQL
+// type used to compare cursor values against it.
+func productSortColumn(sort string) (column, cast string) {
+	switch sort {
+	case "price":
+		return "price_minor", "bigint"
+	case "name":
+		return "name", "text"
+	case "created_at":
+		return "created_at", "timestamptz"
+	default:
+		return "id", "int"
+	}
+}
+
+// productOrderClause maps the whitelisted sort field to SQL. The id tiebreaker
+// keeps pagination stable when sort values repeat.
+func productOrderClause(filter models.ProductFilter) string {
+	column, _ := productSortColumn(filter.Sort)
+
+	direction := "ASC"
+	if filter.Order == "desc" {
+		direction = "DESC"
+	}
+
+	if column == "id" {
+		return "id " + direction
+	}
+	return column + " " + direction + ", id " + direction
+}
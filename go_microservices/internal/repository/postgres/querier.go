@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so the same query code
+// can run standalone or as part of a larger transaction.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func beginTx(db *sql.DB) (repository.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// sqlTx unwraps a transaction started by one of the postgres repositories.
+func sqlTx(tx repository.Tx) *sql.Tx {
+	return tx.(*sql.Tx)
+}
+
+// noRowsReason explains why a write guarded by version matched no rows: the
+// row is gone (or soft-deleted), or it has changed since the caller read it.
+// A zero version means the write was unconditional.
+func noRowsReason(q querier, table string, id, version int) error {
+	if version == 0 {
+		return sql.ErrNoRows
+	}
+
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return repository.ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
+
+// constraintError replaces unique and foreign key violations reported by
+// Postgres with repository.ErrDuplicate and repository.ErrForeignKey, so that
+// callers need not know the driver. Other errors are returned unchanged.
+func constraintError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return fmt.Errorf("%w: %s", repository.ErrDuplicate, pqErr.Constraint)
+	case "23503": // foreign_key_violation
+		return fmt.Errorf("%w: %s", repository.ErrForeignKey, pqErr.Constraint)
+	}
+	return err
+}
+
+// setClause collects the column assignments of a partial UPDATE.
+type setClause struct {
+	columns []string
+	args    []interface{}
+}
+
+func (s *setClause) add(column string, value interface{}) {
+	s.args = append(s.args, value)
+	s.columns = append(s.columns, fmt.Sprintf("%s = $%d", column, len(s.args)))
+}
+
+// update builds an UPDATE of row id that also bumps version and updated_at,
+// guarded by version the same way as the full updates.
+func (s *setClause) update(table string, id, version int) (string, []interface{}) {
+	args := append(append([]interface{}{}, s.args...), id, version)
+	columns := append(append([]string{}, s.columns...), "version = version + 1", "updated_at = NOW()")
+
+	query := fmt.Sprintf(`
+        UPDATE %s
+        SET %s
+        WHERE id = $%d AND deleted_at IS NULL AND ($%d = 0 OR version = $%d)
+    `, table, strings.Join(columns, ", "), len(args)-1, len(args), len(args))
+	return query, args
+}
+
+// insertBatchSize caps the rows of one multi-row INSERT, keeping it far below
+// the bind parameter limit of a Postgres statement.
+const insertBatchSize = 500
+
+// valuesList returns the placeholders of a multi-row VALUES clause, e.g.
+// ($1, $2), ($3, $4) for two rows of two columns.
+func valuesList(rows, columns int) string {
+	var b strings.Builder
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j := 0; j < columns; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", i*columns+j+1)
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// exportBatchSize is how many rows an export fetches from its cursor at once.
+const exportBatchSize = 1000
+
+// streamRows runs query through a server-side cursor in a read-only snapshot
+// and calls scan for every row. Rows are fetched exportBatchSize at a time, so
+// memory use does not grow with the size of the table. The transaction is
+// bound to ctx: when the client goes away, it is rolled back, which closes the
+// cursor and releases the snapshot and the connection.
+func streamRows(ctx context.Context, db *sql.DB, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return err
+	}
+
+	fetch := "FETCH " + strconv.Itoa(exportBatchSize) + " FROM export_cursor"
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return err
+		}
+
+		n := 0
+		for rows.Next() {
+			n++
+			if err := scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if n < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// exportClause builds the WHERE clause of an export and its positional
+// arguments.
+func exportClause(filter models.ExportFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.UpdatedSince != nil {
+		args = append(args, *filter.UpdatedSince)
+		conditions = append(conditions, fmt.Sprintf("updated_at >= $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type ReservationRepository struct {
+	db *sql.DB
+}
+
+func NewReservationRepository(db *sql.DB) *ReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+func (r *ReservationRepository) BeginTx() (repository.Tx, error) {
+	return beginTx(r.db)
+}
+
+func (r *ReservationRepository) CreateTx(tx repository.Tx, reservation *models.Reservation) error {
+	query := `
+        INSERT INTO reservations (product_id, quantity, status, expires_at, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, NOW(), NOW())
+        RETURNING id, created_at, updated_at
+    `
+
+	return sqlTx(tx).QueryRow(
+		query, reservation.ProductID, reservation.Quantity,
+		reservation.Status, reservation.ExpiresAt,
+	).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+}
+
+func (r *ReservationRepository) GetByID(id int) (*models.Reservation, error) {
+	return r.getByID(r.db, id, "")
+}
+
+// GetByIDForUpdate loads a reservation and locks its row until tx finishes.
+func (r *ReservationRepository) GetByIDForUpdate(tx repository.Tx, id int) (*models.Reservation, error) {
+	return r.getByID(sqlTx(tx), id, "FOR UPDATE")
+}
+
+func (r *ReservationRepository) getByID(q querier, id int, lock string) (*models.Reservation, error) {
+	query := `
+        SELECT id, product_id, quantity, status, expires_at, created_at, updated_at
+        FROM reservations
+        WHERE id = $1
+    ` + lock
+
+	var res models.Reservation
+	err := q.QueryRow(query, id).Scan(
+		&res.ID, &res.ProductID, &res.Quantity, &res.Status,
+		&res.ExpiresAt, &res.CreatedAt, &res.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &res, err
+}
+
+func (r *ReservationRepository) UpdateStatusTx(tx repository.Tx, reservation *models.Reservation) error {
+	query := `
+        UPDATE reservations
+        SET status = $1,
+            updated_at = NOW()
+        WHERE id = $2
+        RETURNING updated_at
+    `
+
+	return sqlTx(tx).QueryRow(query, reservation.Status, reservation.ID).Scan(&reservation.UpdatedAt)
+}
+
+// ExpireDue marks every active reservation past its deadline as expired and
+// returns the IDs of the affected products.
+func (r *ReservationRepository) ExpireDue(now time.Time) ([]int, error) {
+	query := `
+        UPDATE reservations
+        SET status = 'expired',
+            updated_at = NOW()
+        WHERE status = 'active' AND expires_at <= $1
+        RETURNING product_id
+    `
+
+	rows, err := r.db.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int]bool)
+	var productIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			productIDs = append(productIDs, id)
+		}
+	}
+	return productIDs, rows.Err()
+}
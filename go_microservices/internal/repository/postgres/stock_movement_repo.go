@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type StockMovementRepository struct {
+	db *sql.DB
+}
+
+func NewStockMovementRepository(db *sql.DB) *StockMovementRepository {
+	return &StockMovementRepository{db: db}
+}
+
+// CreateTx appends a movement inside the transaction that changed the stock.
+func (r *StockMovementRepository) CreateTx(tx repository.Tx, movement *models.StockMovement) error {
+	query := `
+        INSERT INTO stock_movements (product_id, reason, delta, resulting_stock, actor, reference, created_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NOW())
+        RETURNING id, created_at
+    `
+
+	return sqlTx(tx).QueryRow(
+		query, movement.ProductID, movement.Reason, movement.Delta,
+		movement.ResultingStock, movement.Actor, movement.Reference,
+	).Scan(&movement.ID, &movement.CreatedAt)
+}
+
+// CreateManyTx appends movements with multi-row INSERTs of up to
+// insertBatchSize rows.
+func (r *StockMovementRepository) CreateManyTx(tx repository.Tx, movements []*models.StockMovement) error {
+	for start := 0; start < len(movements); start += insertBatchSize {
+		batch := movements[start:min(start+insertBatchSize, len(movements))]
+
+		args := make([]interface{}, 0, len(batch)*6)
+		for _, m := range batch {
+			var reference interface{}
+			if m.Reference != "" {
+				reference = m.Reference
+			}
+			args = append(args, m.ProductID, m.Reason, m.Delta, m.ResultingStock, m.Actor, reference)
+		}
+
+		query := `
+        INSERT INTO stock_movements (product_id, reason, delta, resulting_stock, actor, reference)
+        VALUES ` + valuesList(len(batch), 6) + `
+        RETURNING id, created_at
+    `
+
+		rows, err := sqlTx(tx).Query(query, args...)
+		if err != nil {
+			return err
+		}
+
+		i := 0
+		for rows.Next() {
+			m := batch[i]
+			if err := rows.Scan(&m.ID, &m.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			i++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *StockMovementRepository) GetByProductID(productID, limit, offset int) ([]models.StockMovement, error) {
+	query := `
+        SELECT id, product_id, reason, delta, resulting_stock, actor,
+               COALESCE(reference, ''), created_at
+        FROM stock_movements
+        WHERE product_id = $1
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3
+    `
+
+	rows, err := r.db.Query(query, productID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []models.StockMovement
+	for rows.Next() {
+		var m models.StockMovement
+		if err := rows.Scan(
+			&m.ID, &m.ProductID, &m.Reason, &m.Delta, &m.ResultingStock,
+			&m.Actor, &m.Reference, &m.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+
+	if movements == nil {
+		return []models.StockMovement{}, nil
+	}
+	return movements, nil
+}
+
+func (r *StockMovementRepository) CountByProductID(productID int) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM stock_movements WHERE product_id = $1", productID).Scan(&count)
+	return count, err
+}
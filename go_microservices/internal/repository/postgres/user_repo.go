@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
 	"go_microservices/internal/models"
 )
@@ -16,26 +18,40 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 func (r *UserRepository) Create(user *models.User) error {
 	query := `
-        INSERT INTO users (name, email, created_at, updated_at)
-        VALUES ($1, $2, NOW(), NOW())
-        RETURNING id, created_at, updated_at
+        INSERT INTO users (name, email, password_hash, role, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, NOW(), NOW())
+        RETURNING id, version, created_at, updated_at
     `
 
-	return r.db.QueryRow(query, user.Name, user.Email).Scan(
-		&user.ID, &user.CreatedAt, &user.UpdatedAt,
+	if user.Role == "" {
+		user.Role = models.RoleCustomer
+	}
+	err := r.db.QueryRow(query, user.Name, user.Email, user.PasswordHash, user.Role).Scan(
+		&user.ID, &user.Version, &user.CreatedAt, &user.UpdatedAt,
 	)
+	return constraintError(err)
 }
 
 func (r *UserRepository) GetByID(id int) (*models.User, error) {
+	return r.getByID(id, "AND deleted_at IS NULL")
+}
+
+// GetByIDWithDeleted also finds soft-deleted users.
+func (r *UserRepository) GetByIDWithDeleted(id int) (*models.User, error) {
+	return r.getByID(id, "")
+}
+
+func (r *UserRepository) getByID(id int, scope string) (*models.User, error) {
 	query := `
-        SELECT id, name, email, created_at, updated_at
+        SELECT id, name, email, role, version, created_at, updated_at, deleted_at
         FROM users
-        WHERE id = $1
+        WHERE id = $1 ` + scope + `
     `
 
 	var user models.User
 	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Name, &user.Email, &user.Role, &user.Version,
+		&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -43,16 +59,19 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 	return &user, err
 }
 
+// GetByEmail also finds soft-deleted users: an email stays taken until the
+// user is purged. Unlike the other reads it loads the password hash.
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	query := `
-        SELECT id, name, email, created_at, updated_at
+        SELECT id, name, email, role, version, created_at, updated_at, deleted_at, password_hash
         FROM users
         WHERE email = $1
     `
 
 	var user models.User
 	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Name, &user.Email, &user.Role, &user.Version,
+		&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt, &user.PasswordHash,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -60,10 +79,11 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, err
 }
 
-func (r *UserRepository) GetAll(limit, offset int) ([]models.User, error) {
+func (r *UserRepository) GetAll(limit, offset int, includeDeleted bool) ([]models.User, error) {
 	query := `
-        SELECT id, name, email, created_at, updated_at
+        SELECT id, name, email, role, version, created_at, updated_at, deleted_at
         FROM users
+        ` + userScope(includeDeleted, "WHERE") + `
         ORDER BY id
         LIMIT $1 OFFSET $2
     `
@@ -77,7 +97,9 @@ func (r *UserRepository) GetAll(limit, offset int) ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(
+			&u.ID, &u.Name, &u.Email, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt,
+		); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -89,29 +111,161 @@ func (r *UserRepository) GetAll(limit, offset int) ([]models.User, error) {
 	return users, nil
 }
 
-func (r *UserRepository) Update(id int, user *models.User) error {
+// GetPage returns up to limit users after (or, for a backward cursor, before)
+// the cursor position, in id order, and whether more rows exist in the
+// direction of travel.
+func (r *UserRepository) GetPage(cursor *models.Cursor, limit int, includeDeleted bool) ([]models.User, bool, error) {
+	backward := cursor != nil && cursor.Backward
+
+	where, direction := userScope(includeDeleted, "WHERE"), "ASC"
+	args := []interface{}{limit + 1}
+	if cursor != nil {
+		args = append(args, cursor.ID)
+		where = "WHERE id > $2 " + userScope(includeDeleted, "AND")
+		if backward {
+			where, direction = "WHERE id < $2 "+userScope(includeDeleted, "AND"), "DESC"
+		}
+	}
+
+	query := `
+        SELECT id, name, email, role, version, created_at, updated_at, deleted_at
+        FROM users
+        ` + where + `
+        ORDER BY id ` + direction + `
+        LIMIT $1
+    `
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(
+			&u.ID, &u.Name, &u.Email, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt,
+		); err != nil {
+			return nil, false, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	return users, hasMore, nil
+}
+
+// Export calls fn for every user matching filter, in id order, streaming them
+// from a server-side cursor.
+func (r *UserRepository) Export(ctx context.Context, filter models.ExportFilter, fn func(models.User) error) error {
+	where, args := exportClause(filter)
+	query := `
+        SELECT id, name, email, role, version, created_at, updated_at, deleted_at
+        FROM users
+        ` + where + `
+        ORDER BY id
+    `
+
+	return streamRows(ctx, r.db, query, args, func(rows *sql.Rows) error {
+		var u models.User
+		if err := rows.Scan(
+			&u.ID, &u.Name, &u.Email, &u.Role, &u.Version, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt,
+		); err != nil {
+			return err
+		}
+		return fn(u)
+	})
+}
+
+// Update replaces the editable fields of the user. A non-zero version makes
+// the write conditional on the row still being at that version.
+func (r *UserRepository) Update(id int, user *models.User, version int) error {
 	query := `
         UPDATE users
-        SET name = COALESCE($1, name),
-            email = COALESCE($2, email),
+        SET name = $1,
+            email = $2,
+            version = version + 1,
             updated_at = NOW()
-        WHERE id = $3
-        RETURNING updated_at
+        WHERE id = $3 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)
+        RETURNING version, updated_at
     `
 
-	var name, email *string
-	if user.Name != "" {
-		name = &user.Name
+	err := r.db.QueryRow(query, user.Name, user.Email, id, version).Scan(&user.Version, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return noRowsReason(r.db, "users", id, version)
 	}
-	if user.Email != "" {
-		email = &user.Email
+	return constraintError(err)
+}
+
+// Patch updates only the fields present in patch, under the same version
+// rule as Update.
+func (r *UserRepository) Patch(id int, patch *models.UserPatch, version int) error {
+	var set setClause
+	if patch.Name.Set {
+		set.add("name", patch.Name.Value)
+	}
+	if patch.Email.Set {
+		set.add("email", patch.Email.Value)
+	}
+
+	query, args := set.update("users", id, version)
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return constraintError(err)
 	}
 
-	return r.db.QueryRow(query, name, email, id).Scan(&user.UpdatedAt)
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return noRowsReason(r.db, "users", id, version)
+	}
+	return nil
 }
 
-func (r *UserRepository) Delete(id int) error {
-	result, err := r.db.Exec("DELETE FROM users WHERE id = $1", id)
+// SetRole changes the role of the user under the same version rule as Update.
+func (r *UserRepository) SetRole(id int, role string, version int) error {
+	query := `
+        UPDATE users
+        SET role = $1,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)
+    `
+
+	result, err := r.db.Exec(query, role, id, version)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return noRowsReason(r.db, "users", id, version)
+	}
+	return nil
+}
+
+// SetPasswordHash replaces the password hash of the user if it is still
+// oldHash. Rehashing does not change what clients see, so the version and
+// updated_at are left alone. It returns sql.ErrNoRows if the hash has changed.
+func (r *UserRepository) SetPasswordHash(id int, oldHash, newHash string) error {
+	query := `
+        UPDATE users
+        SET password_hash = $1
+        WHERE id = $2 AND password_hash = $3
+    `
+
+	result, err := r.db.Exec(query, newHash, id, oldHash)
 	if err != nil {
 		return err
 	}
@@ -123,8 +277,83 @@ func (r *UserRepository) Delete(id int) error {
 	return nil
 }
 
-func (r *UserRepository) Count() (int, error) {
+// Delete soft-deletes the user under the same version rule as Update.
+func (r *UserRepository) Delete(id, version int) error {
+	query := `
+        UPDATE users
+        SET deleted_at = NOW(),
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+    `
+
+	result, err := r.db.Exec(query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return noRowsReason(r.db, "users", id, version)
+	}
+	return nil
+}
+
+// Restore undoes a soft delete. It returns sql.ErrNoRows unless the user
+// exists and is deleted.
+func (r *UserRepository) Restore(id int) error {
+	query := `
+        UPDATE users
+        SET deleted_at = NULL,
+            version = version + 1,
+            updated_at = NOW()
+        WHERE id = $1 AND deleted_at IS NOT NULL
+    `
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Purge hard-deletes users soft-deleted before the given time. Users that
+// still have orders are kept so order history stays intact; kept is how many
+// of them were due.
+func (r *UserRepository) Purge(before time.Time) (purged, kept int64, err error) {
+	// The outer SELECT sees the table as it was before the DELETE, so the
+	// expired users it counts include the purged ones.
+	query := `
+        WITH purged AS (
+            DELETE FROM users
+            WHERE deleted_at < $1
+              AND NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = users.id)
+            RETURNING id
+        )
+        SELECT (SELECT COUNT(*) FROM purged),
+               (SELECT COUNT(*) FROM users WHERE deleted_at < $1) - (SELECT COUNT(*) FROM purged)
+    `
+
+	err = r.db.QueryRow(query, before).Scan(&purged, &kept)
+	return purged, kept, err
+}
+
+func (r *UserRepository) Count(includeDeleted bool) (int, error) {
 	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users " + userScope(includeDeleted, "WHERE")).Scan(&count)
 	return count, err
 }
+
+// userScope hides soft-deleted users unless includeDeleted is set. keyword is
+// WHERE or AND, depending on where the condition goes.
+func userScope(includeDeleted bool, keyword string) string {
+	if includeDeleted {
+		return ""
+	}
+	return keyword + " deleted_at IS NULL"
+}
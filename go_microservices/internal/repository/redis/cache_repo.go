@@ -3,22 +3,74 @@ package redis
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"go_microservices/internal/cache"
 )
 
+// deleteVersionTTL keeps the delete version of a key well past the longest
+// load that may have read it, after which the count starts over.
+const deleteVersionTTL = time.Minute
+
+// setIfVersionScript stores a value only while the delete version of its key
+// is unchanged, so the check and the write are one atomic step.
+//
+// KEYS[1] - the key; KEYS[2] - its delete version key; ARGV[1] - the version
+// the load started with; ARGV[2] - the value; ARGV[3] - the TTL in
+// milliseconds, 0 for none.
+var setIfVersionScript = redis.NewScript(`
+local version = redis.call('GET', KEYS[2]) or '0'
+if version ~= ARGV[1] then
+    return 0
+end
+if tonumber(ARGV[3]) > 0 then
+    redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+else
+    redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
 type CacheRepository struct {
+	*cache.ReadThrough
+
 	client *redis.Client
 	ttl    time.Duration
 }
 
-func NewCacheRepository(client *redis.Client, ttl time.Duration) *CacheRepository {
-	return &CacheRepository{
+func NewCacheRepository(client *redis.Client, opts cache.Options) *CacheRepository {
+	r := &CacheRepository{
 		client: client,
-		ttl:    ttl,
+		ttl:    opts.TTL,
+	}
+	r.ReadThrough = cache.NewReadThrough(r, opts)
+	return r
+}
+
+func (r *CacheRepository) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, cache.ErrCacheMiss
+	}
+	return data, err
+}
+
+func (r *CacheRepository) SetBytes(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (r *CacheRepository) DeleteVersion(ctx context.Context, key string) (int64, error) {
+	version, err := r.client.Get(ctx, cache.DeleteVersionKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
 	}
+	return version, err
+}
+
+func (r *CacheRepository) SetBytesIfVersion(ctx context.Context, key string, version int64, data []byte, ttl time.Duration) error {
+	keys := []string{key, cache.DeleteVersionKey(key)}
+	return setIfVersionScript.Run(ctx, r.client, keys, version, data, ttl.Milliseconds()).Err()
 }
 
 func (r *CacheRepository) Set(ctx context.Context, key string, value interface{}) error {
@@ -26,19 +78,31 @@ func (r *CacheRepository) Set(ctx context.Context, key string, value interface{}
 	if err != nil {
 		return err
 	}
-	return r.client.Set(ctx, key, data, r.ttl).Err()
+	return r.client.Set(ctx, key, data, cache.WithJitter(r.ttl)).Err()
 }
 
 func (r *CacheRepository) Get(ctx context.Context, key string, dest interface{}) error {
-	data, err := r.client.Get(ctx, key).Bytes()
+	data, err := r.GetBytes(ctx, key)
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal(data, dest)
 }
 
+// Delete bumps the delete version of every key along with removing it, so
+// loads already in flight on any replica do not store what they read.
 func (r *CacheRepository) Delete(ctx context.Context, keys ...string) error {
-	return r.client.Del(ctx, keys...).Err()
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := r.client.TxPipeline()
+	for _, key := range keys {
+		pipe.Incr(ctx, cache.DeleteVersionKey(key))
+		pipe.PExpire(ctx, cache.DeleteVersionKey(key), deleteVersionTTL)
+	}
+	pipe.Del(ctx, keys...)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 func (r *CacheRepository) Exists(ctx context.Context, key string) (bool, error) {
@@ -50,19 +114,14 @@ func (r *CacheRepository) Flush(ctx context.Context) error {
 	return r.client.FlushDB(ctx).Err()
 }
 
-// Вспомогательные методы для формирования ключей
-func UserKey(id int) string {
-	return fmt.Sprintf("user:%d", id)
-}
-
-func UserListKey(page, limit int) string {
-	return fmt.Sprintf("users:list:%d:%d", page, limit)
-}
-
-func ProductKey(id int) string {
-	return fmt.Sprintf("product:%d", id)
+func (r *CacheRepository) Generation(ctx context.Context, namespace string) (int64, error) {
+	gen, err := r.client.Get(ctx, cache.GenerationKey(namespace)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return gen, err
 }
 
-func ProductListKey(page, limit int) string {
-	return fmt.Sprintf("products:list:%d:%d", page, limit)
+func (r *CacheRepository) BumpGeneration(ctx context.Context, namespace string) error {
+	return r.client.Incr(ctx, cache.GenerationKey(namespace)).Err()
 }
@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationBus broadcasts cache evictions to every replica over Redis
+// pub/sub.
+type InvalidationBus struct {
+	client  *redis.Client
+	channel string
+}
+
+func NewInvalidationBus(client *redis.Client, channel string) *InvalidationBus {
+	return &InvalidationBus{
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (b *InvalidationBus) Publish(ctx context.Context, keys []string) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe calls handler for every published eviction until ctx is done.
+func (b *InvalidationBus) Subscribe(ctx context.Context, handler func(keys []string)) {
+	sub := b.client.Subscribe(ctx, b.channel)
+
+	go func() {
+		defer sub.Close()
+
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				var keys []string
+				if err := json.Unmarshal([]byte(msg.Payload), &keys); err != nil {
+					log.Printf("Invalid cache invalidation message: %v", err)
+					continue
+				}
+				handler(keys)
+			}
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go_microservices/internal/ratelimit"
+)
+
+// slidingWindowScript keeps a sorted set of request timestamps per key. It
+// drops the entries older than the window, counts the rest and records the
+// request if there is room and ARGV[4] is 1, all in one atomic step. The clock is Redis's, so
+// replicas with skewed clocks still share one window.
+//
+// KEYS[1] - the key; ARGV[1] - the request limit; ARGV[2] - the window in
+// milliseconds; ARGV[3] - a unique member for this request; ARGV[4] - 1 to
+// record the request, 0 to only check it.
+// Returns {allowed (0 or 1), remaining, milliseconds until reset}.
+var slidingWindowScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count < limit then
+    if ARGV[4] == '1' then
+        redis.call('ZADD', KEYS[1], now, ARGV[3])
+        count = count + 1
+    end
+    allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+    reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, limit - count, reset}
+`)
+
+// RateLimiter is a sliding-window log limiter shared by every replica.
+type RateLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRateLimiter(client *redis.Client) *RateLimiter {
+	return &RateLimiter{
+		client: client,
+		prefix: "ratelimit:",
+	}
+}
+
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return l.run(ctx, key, limit, 1)
+}
+
+func (l *RateLimiter) Check(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return l.run(ctx, key, limit, 0)
+}
+
+func (l *RateLimiter) run(ctx context.Context, key string, limit ratelimit.Limit, record int) (ratelimit.Result, error) {
+	member := fmt.Sprintf("%d-%x", time.Now().UnixNano(), rand.Uint64())
+
+	values, err := slidingWindowScript.Run(ctx, l.client,
+		[]string{l.prefix + key}, limit.Requests, limit.Window.Milliseconds(), member, record,
+	).Int64Slice()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if len(values) != 3 {
+		return ratelimit.Result{}, fmt.Errorf("rate limit script returned %d values", len(values))
+	}
+
+	return ratelimit.Result{
+		Allowed:   values[0] == 1,
+		Remaining: int(values[1]),
+		Reset:     time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
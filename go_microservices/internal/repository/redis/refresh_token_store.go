@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const refreshTokenPrefix = "refresh_token:"
+
+// RefreshTokenStore keeps refresh tokens in Redis, so that every replica sees
+// a revocation and expired tokens disappear on their own.
+type RefreshTokenStore struct {
+	client *redis.Client
+}
+
+func NewRefreshTokenStore(client *redis.Client) *RefreshTokenStore {
+	return &RefreshTokenStore{client: client}
+}
+
+func (s *RefreshTokenStore) Save(ctx context.Context, tokenHash string, userID int, ttl time.Duration) error {
+	return s.client.Set(ctx, refreshTokenPrefix+tokenHash, userID, ttl).Err()
+}
+
+// Consume uses GETDEL, so of two concurrent calls with one token only one
+// gets the user.
+func (s *RefreshTokenStore) Consume(ctx context.Context, tokenHash string) (int, error) {
+	value, err := s.client.GetDel(ctx, refreshTokenPrefix+tokenHash).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
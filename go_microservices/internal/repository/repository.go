@@ -0,0 +1,24 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrVersionConflict is returned by versioned writes when the row exists
+	// but its version no longer matches the one the caller read.
+	ErrVersionConflict = errors.New("row version conflict")
+
+	// ErrDuplicate is returned, wrapped with the constraint name, when a write
+	// violates a unique constraint.
+	ErrDuplicate = errors.New("duplicate key")
+
+	// ErrForeignKey is returned, wrapped with the constraint name, when a write
+	// violates a foreign key constraint.
+	ErrForeignKey = errors.New("foreign key violation")
+)
+
+// Tx is a unit of work spanning several repository calls. Implementations
+// pass it back to repository methods of the same backend only.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
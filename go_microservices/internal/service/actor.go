@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+)
+
+type actorKey struct{}
+
+// WithActor records who initiated the operation, for audit trails such as the
+// stock movement ledger.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return "system"
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+)
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to spot,
+// e.g. by secret scanners.
+const apiKeyPrefix = "gmk_"
+
+// lastUsedInterval is how often a replica writes back the use of a key.
+// Writing on every request would turn each read into a write.
+const lastUsedInterval = time.Minute
+
+// revocationCheckInterval is how long a replica trusts a cached key before it
+// reads again whether the key was revoked. Revoke evicts the cached key, but
+// a lookup that read the key just before the revocation may store it again
+// afterwards; the check bounds how long such a key keeps working.
+const revocationCheckInterval = 10 * time.Second
+
+type APIKeyService struct {
+	apiKeyRepo APIKeyRepository
+	cacheRepo  cache.Cache
+
+	mu       sync.Mutex
+	lastUsed map[int]time.Time
+	checked  map[int]time.Time
+}
+
+func NewAPIKeyService(apiKeyRepo APIKeyRepository, cacheRepo cache.Cache) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		cacheRepo:  cacheRepo,
+		lastUsed:   make(map[int]time.Time),
+		checked:    make(map[int]time.Time),
+	}
+}
+
+// Create issues a key with the requested scopes. Only its hash is stored, so
+// the returned key cannot be shown again.
+func (s *APIKeyService) Create(ctx context.Context, req *models.CreateAPIKeyRequest, createdBy *int) (*models.CreatedAPIKey, error) {
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !models.ValidScope(scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	secret, err := auth.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + secret
+
+	apiKey := &models.APIKey{
+		Name:      req.Name,
+		Prefix:    key[:len(apiKeyPrefix)+6],
+		KeyHash:   auth.HashSecret(key),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.apiKeyRepo.Create(apiKey); err != nil {
+		return nil, fromRepository(err, ErrAPIKeyNotFound)
+	}
+
+	return &models.CreatedAPIKey{APIKey: *apiKey, Key: key}, nil
+}
+
+func (s *APIKeyService) GetAll(ctx context.Context) ([]models.APIKey, error) {
+	return s.apiKeyRepo.GetAll()
+}
+
+// Revoke disables a key at once on every replica: the cached lookup is
+// evicted along with the key.
+func (s *APIKeyService) Revoke(ctx context.Context, id int) error {
+	if err := s.apiKeyRepo.Revoke(id); err != nil {
+		return fromRepository(err, ErrAPIKeyNotFound)
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if apiKey != nil {
+		s.cacheRepo.Delete(ctx, cache.APIKeyKey(apiKey.KeyHash))
+	}
+	return nil
+}
+
+// Authenticate resolves the key a request carries. Lookups are cached, and so
+// are unknown keys, so guessing keys does not reach the database. Whether a
+// cached key was revoked is read again every revocationCheckInterval.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	keyHash := auth.HashSecret(key)
+
+	var apiKey models.APIKey
+	found, err := s.cacheRepo.GetOrLoad(ctx, cache.APIKeyKey(keyHash), &apiKey, func(ctx context.Context) (interface{}, error) {
+		readAt := time.Now()
+		apiKeyPtr, err := s.apiKeyRepo.GetByHash(keyHash)
+		if err != nil || apiKeyPtr == nil {
+			return nil, err
+		}
+		s.markChecked(apiKeyPtr.ID, readAt)
+		return apiKeyPtr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !found || !apiKey.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if s.checkDue(apiKey.ID, now) {
+		current, err := s.apiKeyRepo.GetByID(apiKey.ID)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil || !current.Active(now) {
+			s.cacheRepo.Delete(ctx, cache.APIKeyKey(keyHash))
+			return nil, ErrInvalidAPIKey
+		}
+		s.markChecked(apiKey.ID, now)
+	}
+
+	s.touch(apiKey.ID, now)
+	return &apiKey, nil
+}
+
+// checkDue reports whether the revocation of a key was last read from the
+// database more than revocationCheckInterval before at.
+func (s *APIKeyService) checkDue(id int, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return at.Sub(s.checked[id]) >= revocationCheckInterval
+}
+
+func (s *APIKeyService) markChecked(id int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at.After(s.checked[id]) {
+		s.checked[id] = at
+	}
+}
+
+// touch records the use of a key, at most once per lastUsedInterval.
+func (s *APIKeyService) touch(id int, at time.Time) {
+	s.mu.Lock()
+	if at.Sub(s.lastUsed[id]) < lastUsedInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastUsed[id] = at
+	s.mu.Unlock()
+
+	if err := s.apiKeyRepo.TouchLastUsed(id, at); err != nil {
+		log.Printf("Failed to record use of API key %d: %v", id, err)
+	}
+}
@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository/memory"
+	"go_microservices/internal/service"
+)
+
+func TestAPIKeyServiceRevoke(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewAPIKeyRepository(memory.NewStore())
+	keys := service.NewAPIKeyService(repo, cache.NewMemoryCache(100, cache.Options{TTL: time.Hour, NegativeTTL: time.Hour}))
+
+	created, err := keys.Create(ctx, &models.CreateAPIKeyRequest{Name: "sync", Scopes: []string{models.ScopeStockWrite}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, created.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keys.Revoke(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, created.Key); !errors.Is(err, service.ErrInvalidAPIKey) {
+		t.Fatalf("revoked key: got %v, want %v", err, service.ErrInvalidAPIKey)
+	}
+	if _, err := keys.Authenticate(ctx, "gmk_unknown"); !errors.Is(err, service.ErrInvalidAPIKey) {
+		t.Fatalf("unknown key: got %v, want %v", err, service.ErrInvalidAPIKey)
+	}
+}
+
+func TestAPIKeyServiceRechecksCachedKeys(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewAPIKeyRepository(memory.NewStore())
+	keys := service.NewAPIKeyService(repo, cache.NewMemoryCache(100, cache.Options{TTL: time.Hour}))
+
+	created, err := keys.Create(ctx, &models.CreateAPIKeyRequest{Name: "sync", Scopes: []string{models.ScopeStockWrite}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, created.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revoking behind the cache leaves the active key cached, as a lookup
+	// that stores its result after Revoke evicted the key does.
+	if err := repo.Revoke(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(ctx, created.Key); err != nil {
+		t.Fatalf("cached key within the check interval: got %v", err)
+	}
+
+	keys.ExpireRevocationChecks()
+	if _, err := keys.Authenticate(ctx, created.Key); !errors.Is(err, service.ErrInvalidAPIKey) {
+		t.Fatalf("cached key after the check interval: got %v, want %v", err, service.ErrInvalidAPIKey)
+	}
+	if _, err := keys.Authenticate(ctx, created.Key); !errors.Is(err, service.ErrInvalidAPIKey) {
+		t.Fatalf("key after the stale entry was evicted: got %v, want %v", err, service.ErrInvalidAPIKey)
+	}
+}
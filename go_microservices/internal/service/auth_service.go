@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+)
+
+type AuthService struct {
+	userRepo     UserRepository
+	tokens       *auth.TokenIssuer
+	refreshStore RefreshTokenStore
+	cacheRepo    cache.Cache
+	refreshTTL   time.Duration
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+func NewAuthService(
+	userRepo UserRepository,
+	tokens *auth.TokenIssuer,
+	refreshStore RefreshTokenStore,
+	cacheRepo cache.Cache,
+	refreshTTL time.Duration,
+) *AuthService {
+	return &AuthService{
+		userRepo:     userRepo,
+		tokens:       tokens,
+		refreshStore: refreshStore,
+		cacheRepo:    cacheRepo,
+		refreshTTL:   refreshTTL,
+	}
+}
+
+// Register creates a user with a password and logs it in.
+func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.RegisterResponse, error) {
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Name:         req.Name,
+		Email:        req.Email,
+		PasswordHash: hash,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, userError(err, user.Email)
+	}
+
+	s.cacheRepo.Delete(ctx, cache.UserKey(user.ID))
+	s.cacheRepo.BumpGeneration(ctx, cache.UsersNamespace)
+
+	tokens, err := s.issue(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RegisterResponse{User: user, TokenPair: *tokens}, nil
+}
+
+// Login checks the password of a user and issues a pair of tokens. Deleted
+// users and users created without a password cannot log in.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.TokenPair, error) {
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.DeletedAt != nil || user.PasswordHash == "" {
+		// Spend as long as a real check, so the response time does not
+		// reveal whether the email has an account.
+		auth.VerifyPassword(s.dummyPasswordHash(), req.Password)
+		return nil, ErrInvalidCredentials
+	}
+	if !auth.VerifyPassword(user.PasswordHash, req.Password) {
+		return nil, ErrInvalidCredentials
+	}
+	if auth.NeedsRehash(user.PasswordHash) {
+		s.rehash(user, req.Password)
+	}
+
+	return s.issue(ctx, user)
+}
+
+// rehash stores the password of a user who has just logged in under the
+// current hashing scheme. A failure only postpones that to the next login, so
+// it does not fail the login.
+func (s *AuthService) rehash(user *models.User, password string) {
+	hash, err := auth.HashPassword(password)
+	if err == nil {
+		err = s.userRepo.SetPasswordHash(user.ID, user.PasswordHash, hash)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Failed to rehash the password of user %d: %v", user.ID, err)
+	}
+}
+
+// Refresh exchanges a refresh token for a new pair. The old token is
+// consumed, so each refresh token can be used once.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	userID, err := s.refreshStore.Consume(ctx, auth.HashSecret(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issue(ctx, user)
+}
+
+// Logout revokes a refresh token. Access tokens already issued stay valid
+// until they expire, which is why they are short-lived.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	userID, err := s.refreshStore.Consume(ctx, auth.HashSecret(refreshToken))
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+func (s *AuthService) issue(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	accessToken, err := s.tokens.Issue(user.ID, user.Email, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshStore.Save(ctx, auth.HashSecret(refreshToken), user.ID, s.refreshTTL); err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokens.TTL().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (s *AuthService) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = auth.HashPassword("")
+	})
+	return s.dummyHash
+}
@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go_microservices/internal/auth"
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository/memory"
+	"go_microservices/internal/service"
+)
+
+func TestAuthServiceLoginRehashesLegacyPasswords(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewUserRepository(memory.NewStore())
+	c := cache.NewMemoryCache(100, cache.Options{TTL: time.Minute, NegativeTTL: time.Minute})
+	tokens := auth.NewTokenIssuer([]byte("0123456789abcdef0123456789abcdef"), time.Minute)
+	auths := service.NewAuthService(users, tokens, memory.NewRefreshTokenStore(), c, time.Hour)
+
+	// "password" hashed with PBKDF2-HMAC-SHA256, salt "salt" and 4096
+	// iterations, as passwords were stored before Argon2id.
+	legacy := "pbkdf2-sha256$4096$c2FsdA$xeR41ZKIyEGqUw22hFxMjZYok6ABzk4RpJY4c6qYE0o"
+	user := &models.User{Name: "Ann", Email: "ann@example.com", PasswordHash: legacy}
+	if err := users.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := auths.Login(ctx, &models.LoginRequest{Email: "ann@example.com", Password: "wrong"})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password: got %v, want %v", err, service.ErrInvalidCredentials)
+	}
+	if got, _ := users.GetByEmail("ann@example.com"); got.PasswordHash != legacy {
+		t.Fatalf("a failed login changed the hash to %q", got.PasswordHash)
+	}
+
+	if _, err := auths.Login(ctx, &models.LoginRequest{Email: "ann@example.com", Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := users.GetByEmail("ann@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.PasswordHash, "$argon2id$") || got.Version != user.Version {
+		t.Fatalf("after login: got hash %q, version %d", got.PasswordHash, got.Version)
+	}
+
+	if _, err := auths.Login(ctx, &models.LoginRequest{Email: "ann@example.com", Password: "password"}); err != nil {
+		t.Fatalf("Login with the rehashed password: %v", err)
+	}
+}
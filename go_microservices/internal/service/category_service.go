@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+)
+
+type CategoryService struct {
+	categoryRepo CategoryRepository
+	cacheRepo    cache.Cache
+}
+
+func NewCategoryService(categoryRepo CategoryRepository, cacheRepo cache.Cache) *CategoryService {
+	return &CategoryService{
+		categoryRepo: categoryRepo,
+		cacheRepo:    cacheRepo,
+	}
+}
+
+// Tree returns the top-level categories with their subcategories. The whole
+// tree is cached as one entry.
+func (s *CategoryService) Tree(ctx context.Context) ([]*models.CategoryNode, error) {
+	var tree []*models.CategoryNode
+
+	_, err := s.cacheRepo.GetOrLoad(ctx, cache.CategoryTreeKey(), &tree, func(ctx context.Context) (interface{}, error) {
+		categories, err := s.categoryRepo.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		return models.BuildCategoryTree(categories), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// GetByID returns a category with its subcategories, or nil if there is no
+// such category.
+func (s *CategoryService) GetByID(ctx context.Context, id int) (*models.CategoryNode, error) {
+	tree, err := s.Tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, root := range tree {
+		if node := root.Find(id); node != nil {
+			return node, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *CategoryService) Create(ctx context.Context, req *models.CreateCategoryRequest) (*models.CategoryNode, error) {
+	category := &models.Category{
+		Name:     strings.TrimSpace(req.Name),
+		ParentID: req.ParentID,
+	}
+	if err := validateCategoryName(category.Name); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.categoryRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.categoryRepo.LockTx(tx); err != nil {
+		return nil, err
+	}
+	if category.ParentID != nil {
+		path, err := s.categoryRepo.GetPathTx(tx, *category.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: parent category %d does not exist", ErrInvalidCategory, *category.ParentID)
+		}
+	}
+
+	if err := s.categoryRepo.CreateTx(tx, category); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// A new category has no products yet, so product listings stay valid.
+	s.cacheRepo.Delete(ctx, cache.CategoryTreeKey())
+
+	return &models.CategoryNode{Category: *category, Children: []*models.CategoryNode{}}, nil
+}
+
+// Update renames a category and moves it under another parent.
+func (s *CategoryService) Update(ctx context.Context, id int, req *models.UpdateCategoryRequest) (*models.CategoryNode, error) {
+	category := &models.Category{
+		ID:       id,
+		Name:     strings.TrimSpace(req.Name),
+		ParentID: req.ParentID,
+	}
+	if err := validateCategoryName(category.Name); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.categoryRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.categoryRepo.LockTx(tx); err != nil {
+		return nil, err
+	}
+	if category.ParentID != nil {
+		path, err := s.categoryRepo.GetPathTx(tx, *category.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: parent category %d does not exist", ErrInvalidCategory, *category.ParentID)
+		}
+		for _, ancestor := range path {
+			if ancestor == id {
+				return nil, fmt.Errorf("%w: a category cannot be moved under itself or its subcategories", ErrInvalidCategory)
+			}
+		}
+	}
+
+	if err := s.categoryRepo.UpdateTx(tx, category); err != nil {
+		return nil, fromRepository(err, ErrCategoryNotFound)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(ctx)
+
+	return s.GetByID(ctx, id)
+}
+
+// Delete removes a category that has no subcategories. Its products stay,
+// unlinked from it.
+func (s *CategoryService) Delete(ctx context.Context, id int) error {
+	tx, err := s.categoryRepo.BeginTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.categoryRepo.LockTx(tx); err != nil {
+		return err
+	}
+	hasChildren, err := s.categoryRepo.HasChildrenTx(tx, id)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("%w: move or delete them first", ErrCategoryHasChildren)
+	}
+
+	if err := s.categoryRepo.DeleteTx(tx, id); err != nil {
+		return fromRepository(err, ErrCategoryNotFound)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.invalidate(ctx)
+
+	return nil
+}
+
+// ProductCategories returns the categories of a product.
+func (s *CategoryService) ProductCategories(ctx context.Context, productID int) ([]models.Category, error) {
+	categories, err := s.categoryRepo.GetByProductID(productID)
+	if err != nil {
+		return nil, fromRepository(err, ErrProductNotFound)
+	}
+	return categories, nil
+}
+
+// SetProductCategories replaces the categories of a product and returns the
+// new set.
+func (s *CategoryService) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) ([]models.Category, error) {
+	ids := make([]int, 0, len(categoryIDs))
+	seen := make(map[int]bool, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	tx, err := s.categoryRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Holding the tree lock keeps the categories from being deleted before
+	// the links are written.
+	if err := s.categoryRepo.LockTx(tx); err != nil {
+		return nil, err
+	}
+	found, err := s.categoryRepo.CountTx(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if found != len(ids) {
+		return nil, fmt.Errorf("%w: some of category IDs %v do not exist", ErrInvalidCategory, ids)
+	}
+
+	if err := s.categoryRepo.SetProductCategoriesTx(tx, productID, ids); err != nil {
+		return nil, fromRepository(err, ErrProductNotFound)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+
+	return s.categoryRepo.GetByProductID(productID)
+}
+
+// invalidate drops the cached tree and, since product listings filtered by
+// category depend on its shape, the cached product listings too.
+func (s *CategoryService) invalidate(ctx context.Context) {
+	s.cacheRepo.Delete(ctx, cache.CategoryTreeKey())
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+}
+
+func validateCategoryName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	case len([]rune(name)) > 100:
+		return fmt.Errorf("%w: name must be at most 100 characters", ErrInvalidCategory)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"go_microservices/internal/repository"
+)
+
+// Kind classifies domain errors by what the caller did wrong, so that a
+// whole class of errors can be handled without listing every one of them.
+type Kind int
+
+const (
+	// KindValidation means the request breaks a business rule.
+	KindValidation Kind = iota + 1
+	// KindNotFound means a resource the request refers to does not exist.
+	KindNotFound
+	// KindConflict means the request clashes with the current state, such
+	// as a taken email or insufficient stock.
+	KindConflict
+	// KindPrecondition means the resource has changed since the version the
+	// caller read.
+	KindPrecondition
+	// KindUnauthenticated means the credentials of the request are missing,
+	// wrong or no longer valid.
+	KindUnauthenticated
+)
+
+// Error is a domain error. Code is a stable identifier clients can match on.
+// Services add details by wrapping an *Error with fmt.Errorf("%w: ...").
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+var (
+	ErrInvalidUpdate    = newError(KindValidation, "invalid_update", "invalid update")
+	ErrInvalidPrice     = newError(KindValidation, "invalid_price", "invalid price")
+	ErrInvalidCurrency  = newError(KindValidation, "invalid_currency", "invalid currency")
+	ErrInvalidCategory  = newError(KindValidation, "invalid_category", "invalid category")
+	ErrInvalidQuantity  = newError(KindValidation, "invalid_quantity", "item quantity must be greater than zero")
+	ErrEmptyOrder       = newError(KindValidation, "empty_order", "order must contain at least one item")
+	ErrPriceUnavailable = newError(KindValidation, "price_unavailable", "price not available")
+	ErrInvalidRole      = newError(KindValidation, "invalid_role", "role must be admin, staff or customer")
+	ErrInvalidScope     = newError(KindValidation, "invalid_scope", "unknown API key scope")
+	ErrInvalidExpiry    = newError(KindValidation, "invalid_expiry", "expiry must be in the future")
+
+	ErrUserNotFound        = newError(KindNotFound, "user_not_found", "user not found")
+	ErrProductNotFound     = newError(KindNotFound, "product_not_found", "product not found")
+	ErrOrderNotFound       = newError(KindNotFound, "order_not_found", "order not found")
+	ErrReservationNotFound = newError(KindNotFound, "reservation_not_found", "reservation not found")
+	ErrCategoryNotFound    = newError(KindNotFound, "category_not_found", "category not found")
+	ErrPriceNotFound       = newError(KindNotFound, "price_not_found", "price not found")
+	ErrAPIKeyNotFound      = newError(KindNotFound, "api_key_not_found", "API key not found")
+
+	ErrEmailTaken           = newError(KindConflict, "email_taken", "email is already taken")
+	ErrInsufficientStock    = newError(KindConflict, "insufficient_stock", "insufficient stock")
+	ErrReservationNotActive = newError(KindConflict, "reservation_not_active", "reservation is not active")
+	ErrReservationExpired   = newError(KindConflict, "reservation_expired", "reservation has expired")
+	ErrCategoryHasChildren  = newError(KindConflict, "category_has_children", "category has subcategories")
+	ErrReferenceViolation   = newError(KindConflict, "reference_violation", "a referenced record does not exist or is still in use")
+
+	// ErrPreconditionFailed is returned when a write carries a version that
+	// no longer matches the stored row.
+	ErrPreconditionFailed = newError(KindPrecondition, "version_mismatch", "resource has been modified")
+
+	// The authentication errors do not tell an unknown email from a wrong
+	// password, so they cannot be used to find out who has an account.
+	ErrInvalidCredentials  = newError(KindUnauthenticated, "invalid_credentials", "invalid email or password")
+	ErrInvalidRefreshToken = newError(KindUnauthenticated, "invalid_refresh_token", "refresh token is invalid, expired or revoked")
+	ErrInvalidAPIKey       = newError(KindUnauthenticated, "invalid_api_key", "API key is invalid, expired or revoked")
+)
+
+// fromRepository replaces the sentinel errors of the repositories with domain
+// errors. What a missing row means depends on the call, so sql.ErrNoRows
+// becomes notFound.
+func fromRepository(err error, notFound *Error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return notFound
+	case errors.Is(err, repository.ErrVersionConflict):
+		return ErrPreconditionFailed
+	case errors.Is(err, repository.ErrForeignKey):
+		return ErrReferenceViolation
+	}
+	return err
+}
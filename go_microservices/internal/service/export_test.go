@@ -0,0 +1,9 @@
+package service
+
+// ExpireRevocationChecks makes the next Authenticate of every key read again
+// whether it was revoked, as if revocationCheckInterval had passed.
+func (s *APIKeyService) ExpireRevocationChecks() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.checked)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+)
+
+type OrderService struct {
+	orderRepo    OrderRepository
+	productRepo  ProductRepository
+	userRepo     UserRepository
+	movementRepo StockMovementRepository
+	cacheRepo    cache.Cache
+}
+
+func NewOrderService(
+	orderRepo OrderRepository,
+	productRepo ProductRepository,
+	userRepo UserRepository,
+	movementRepo StockMovementRepository,
+	cacheRepo cache.Cache,
+) *OrderService {
+	return &OrderService{
+		orderRepo:    orderRepo,
+		productRepo:  productRepo,
+		userRepo:     userRepo,
+		movementRepo: movementRepo,
+		cacheRepo:    cacheRepo,
+	}
+}
+
+// Create places an order and decrements stock for every line item in a single
+// transaction, so either all items are reserved or nothing changes.
+func (s *OrderService) Create(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
+	items, err := mergeOrderItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without an explicit currency the order is priced in the base currency
+	// of its first product.
+	currency := req.Currency
+	if currency != "" {
+		if currency, err = models.NormalizeCurrency(currency); err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidCurrency, req.Currency)
+		}
+	}
+
+	user, err := s.userRepo.GetByID(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	tx, err := s.orderRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order := &models.Order{
+		UserID: req.UserID,
+		Status: models.OrderStatusCreated,
+	}
+	var movements []models.StockMovement
+
+	for _, item := range items {
+		product, err := s.productRepo.GetByIDForUpdate(tx, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if product == nil {
+			return nil, fmt.Errorf("%w: %d", ErrProductNotFound, item.ProductID)
+		}
+		if product.Available < item.Quantity {
+			return nil, fmt.Errorf("%w for product %d: available %d, requested %d",
+				ErrInsufficientStock, product.ID, product.Available, item.Quantity)
+		}
+
+		newStock, err := s.productRepo.UpdateStockTx(tx, product.ID, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, models.StockMovement{
+			ProductID:      product.ID,
+			Reason:         models.StockReasonOrder,
+			Delta:          -item.Quantity,
+			ResultingStock: newStock,
+			Actor:          ActorFromContext(ctx),
+		})
+
+		if currency == "" {
+			currency = product.Price.Currency
+		}
+		if currency != product.Price.Currency {
+			if product.Prices, err = s.productRepo.GetPrices(product.ID); err != nil {
+				return nil, err
+			}
+		}
+		price, ok := product.PriceIn(currency)
+		if !ok {
+			return nil, fmt.Errorf("%w: product %d has no price in %s", ErrPriceUnavailable, product.ID, currency)
+		}
+
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID: product.ID,
+			Quantity:  item.Quantity,
+			UnitPrice: price,
+		})
+		order.Total.Amount += price.Amount * int64(item.Quantity)
+	}
+	order.Total.Currency = currency
+
+	if err := s.orderRepo.CreateTx(tx, order); err != nil {
+		// The user may have been purged since it was looked up.
+		return nil, fromRepository(err, ErrUserNotFound)
+	}
+	for i := range movements {
+		movements[i].Reference = fmt.Sprintf("order:%d", order.ID)
+		if err := s.movementRepo.CreateTx(tx, &movements[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(items)+1)
+	keys = append(keys, cache.OrderKey(order.ID))
+	for _, item := range items {
+		keys = append(keys, cache.ProductKey(item.ProductID))
+	}
+	s.cacheRepo.Delete(ctx, keys...)
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+
+	return order, nil
+}
+
+func (s *OrderService) GetByID(ctx context.Context, id int) (*models.Order, error) {
+	var order models.Order
+
+	found, err := s.cacheRepo.GetOrLoad(ctx, cache.OrderKey(id), &order, func(ctx context.Context) (interface{}, error) {
+		orderPtr, err := s.orderRepo.GetByID(id)
+		if err != nil || orderPtr == nil {
+			return nil, err
+		}
+		return orderPtr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+
+	return &order, nil
+}
+
+func (s *OrderService) GetByUserID(ctx context.Context, userID, page, limit int) ([]models.Order, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return s.orderRepo.GetByUserID(userID, limit, (page-1)*limit)
+}
+
+func (s *OrderService) CountByUserID(ctx context.Context, userID int) (int, error) {
+	return s.orderRepo.CountByUserID(userID)
+}
+
+// mergeOrderItems collapses duplicate products and sorts by product ID so that
+// concurrent orders always lock product rows in the same order.
+func mergeOrderItems(items []models.CreateOrderItemRequest) ([]models.CreateOrderItemRequest, error) {
+	if len(items) == 0 {
+		return nil, ErrEmptyOrder
+	}
+
+	quantities := make(map[int]int, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, ErrInvalidQuantity
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	merged := make([]models.CreateOrderItemRequest, 0, len(quantities))
+	for productID, quantity := range quantities {
+		merged = append(merged, models.CreateOrderItemRequest{
+			ProductID: productID,
+			Quantity:  quantity,
+		})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].ProductID < merged[j].ProductID
+	})
+
+	return merged, nil
+}
@@ -0,0 +1,57 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+func TestOrderServiceCreateIsAllOrNothing(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	user := s.createUser(t, "ann@example.com")
+	plenty := s.createProduct(t, 10)
+	scarce := s.createProduct(t, 1)
+
+	_, err := s.orders.Create(ctx, &models.CreateOrderRequest{
+		UserID: user.ID,
+		Items: []models.CreateOrderItemRequest{
+			{ProductID: plenty.ID, Quantity: 4},
+			{ProductID: scarce.ID, Quantity: 2},
+		},
+	})
+	if !errors.Is(err, service.ErrInsufficientStock) {
+		t.Fatalf("ordering 2 of 1: got %v, want %v", err, service.ErrInsufficientStock)
+	}
+	if got, err := s.products.GetByID(ctx, plenty.ID); err != nil || got.Stock != 10 {
+		t.Fatalf("stock after a failed order: got %v, %v", got, err)
+	}
+
+	order, err := s.orders.Create(ctx, &models.CreateOrderRequest{
+		UserID: user.ID,
+		Items: []models.CreateOrderItemRequest{
+			{ProductID: plenty.ID, Quantity: 4},
+			{ProductID: scarce.ID, Quantity: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Total != (models.Money{Amount: 1250, Currency: "USD"}) || len(order.Items) != 2 {
+		t.Fatalf("order: got total %+v, %d items", order.Total, len(order.Items))
+	}
+	if got, err := s.products.GetByID(ctx, plenty.ID); err != nil || got.Stock != 6 {
+		t.Fatalf("stock after the order: got %v, %v", got, err)
+	}
+
+	_, err = s.orders.Create(ctx, &models.CreateOrderRequest{
+		UserID: 42,
+		Items:  []models.CreateOrderItemRequest{{ProductID: plenty.ID, Quantity: 1}},
+	})
+	if !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("ordering for a missing user: got %v, want %v", err, service.ErrUserNotFound)
+	}
+}
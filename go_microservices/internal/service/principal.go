@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+)
+
+// Principal is the authenticated caller of a request: either a user, logged
+// in with an access token, or a service using an API key.
+type Principal struct {
+	UserID int
+	Email  string
+	Role   string
+
+	// APIKeyID is set instead of the user fields for an API key, which is
+	// limited to its Scopes.
+	APIKeyID int
+	Scopes   []string
+}
+
+// IsAPIKey reports whether the caller authenticated with an API key.
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != 0
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal records the authenticated caller of the operation.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated caller, or nil for an
+// anonymous one.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+)
+
+// importReference marks the stock movements written by an import.
+const importReference = "import"
+
+// Import loads rows in a single transaction, creating and updating products
+// in batches. Rows that fail validation are
+// reported as rejected and skipped; any storage error aborts the whole
+// import. A dry run reports what would happen and writes nothing. List caches
+// are invalidated once, after the commit.
+func (s *ProductService) Import(ctx context.Context, rows []models.ProductImportRow, opts models.ProductImportOptions) (*models.ProductImportReport, error) {
+	report := &models.ProductImportReport{
+		Mode:     opts.Mode,
+		DryRun:   opts.DryRun,
+		Total:    len(rows),
+		Accepted: []models.ProductImportAccepted{},
+		Rejected: []models.ProductImportRejected{},
+	}
+
+	tx, err := s.productRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	existing := make(map[string][]models.Product)
+	if opts.Mode == models.ImportModeUpsert {
+		var names []string
+		for _, row := range rows {
+			if row.Error == "" && row.Fields.Name.Set {
+				names = append(names, row.Fields.Name.Value)
+			}
+		}
+		products, err := s.productRepo.GetByNamesForUpdate(tx, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range products {
+			existing[p.Name] = append(existing[p.Name], p)
+		}
+	}
+
+	var (
+		creates     []*models.Product
+		createIndex []int
+		updates     []*models.Product
+		movements   []*models.StockMovement
+		seen        = make(map[string]int)
+	)
+	reject := func(row models.ProductImportRow, reason string) {
+		report.Rejected = append(report.Rejected, models.ProductImportRejected{
+			Line: row.Line, Name: row.Fields.Name.Value, Reason: reason,
+		})
+	}
+
+	for _, row := range rows {
+		if row.Error != "" {
+			reject(row, row.Error)
+			continue
+		}
+
+		var current *models.Product
+		if opts.Mode == models.ImportModeUpsert && row.Fields.Name.Set {
+			name := row.Fields.Name.Value
+			if line, ok := seen[name]; ok {
+				reject(row, fmt.Sprintf("duplicate name, already imported from line %d", line))
+				continue
+			}
+			switch matches := existing[name]; len(matches) {
+			case 0:
+			case 1:
+				current = &matches[0]
+			default:
+				reject(row, fmt.Sprintf("name matches %d existing products", len(matches)))
+				continue
+			}
+		}
+
+		product, reason := s.importProduct(row.Fields, current)
+		if reason == "" && current != nil && product.Stock != current.Stock && product.Stock < current.Reserved {
+			reason = reservedStockError(current, product.Stock).Error()
+		}
+		if reason != "" {
+			reject(row, reason)
+			continue
+		}
+		if opts.Mode == models.ImportModeUpsert {
+			seen[product.Name] = row.Line
+		}
+
+		accepted := models.ProductImportAccepted{Line: row.Line, Name: product.Name}
+		switch {
+		case current == nil:
+			accepted.Action = models.ImportActionCreated
+			report.Created++
+			creates = append(creates, product)
+			createIndex = append(createIndex, len(report.Accepted))
+		case product.Description == current.Description && product.Price == current.Price && product.Stock == current.Stock:
+			accepted.ID = current.ID
+			accepted.Action = models.ImportActionUnchanged
+			report.Unchanged++
+		default:
+			accepted.ID = current.ID
+			accepted.Action = models.ImportActionUpdated
+			report.Updated++
+			updates = append(updates, product)
+			if delta := product.Stock - current.Stock; delta != 0 {
+				movements = append(movements, &models.StockMovement{
+					ProductID:      current.ID,
+					Reason:         models.StockReasonAdjustment,
+					Delta:          delta,
+					ResultingStock: product.Stock,
+					Actor:          ActorFromContext(ctx),
+					Reference:      importReference,
+				})
+			}
+		}
+		report.Accepted = append(report.Accepted, accepted)
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	if err := s.productRepo.UpdateManyTx(tx, updates); err != nil {
+		return nil, fromRepository(err, ErrProductNotFound)
+	}
+	if err := s.productRepo.CreateManyTx(tx, creates); err != nil {
+		return nil, err
+	}
+	for i, product := range creates {
+		report.Accepted[createIndex[i]].ID = product.ID
+		if product.Stock != 0 {
+			movements = append(movements, &models.StockMovement{
+				ProductID:      product.ID,
+				Reason:         models.StockReasonInitial,
+				Delta:          product.Stock,
+				ResultingStock: product.Stock,
+				Actor:          ActorFromContext(ctx),
+				Reference:      importReference,
+			})
+		}
+	}
+	if err := s.movementRepo.CreateManyTx(tx, movements); err != nil {
+		return nil, err
+	}
+
+	// The statements do not see ctx, so an import that ran past its deadline
+	// or lost its client is rolled back here instead of committed unseen.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, product := range updates {
+		s.cacheRepo.Delete(ctx, cache.ProductKey(product.ID))
+	}
+	if len(creates) > 0 || len(updates) > 0 {
+		s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+	}
+
+	return report, nil
+}
+
+// importProduct merges fields into current, or into a new product when
+// current is nil, and returns the reason the result is invalid, if any. The
+// limits mirror the products table so a bad row cannot abort the transaction.
+func (s *ProductService) importProduct(fields models.ProductPatch, current *models.Product) (*models.Product, string) {
+	product := &models.Product{}
+	if current != nil {
+		*product = *current
+	}
+
+	if fields.Name.Set {
+		product.Name = fields.Name.Value
+	}
+	if fields.Description.Set {
+		product.Description = fields.Description.Value
+	}
+	if fields.Price.Set {
+		if fields.Price.Null {
+			return nil, "price cannot be null"
+		}
+		product.Price = fields.Price.Value
+	}
+	if fields.Stock.Set {
+		if fields.Stock.Null {
+			return nil, "stock cannot be null"
+		}
+		product.Stock = fields.Stock.Value
+	}
+
+	switch {
+	case product.Name == "":
+		return nil, "name is required"
+	case len([]rune(product.Name)) > 200:
+		return nil, "name must be at most 200 characters"
+	case s.basePriceProblem(product.Price) != "":
+		return nil, s.basePriceProblem(product.Price)
+	case product.Stock < 0:
+		return nil, "stock cannot be negative"
+	case product.Stock > math.MaxInt32:
+		return nil, "stock is too large"
+	}
+	return product, ""
+}
@@ -4,25 +4,46 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
 
+	"go_microservices/internal/cache"
 	"go_microservices/internal/models"
-	"go_microservices/internal/repository/postgres"
-	"go_microservices/internal/repository/redis"
+	"go_microservices/internal/repository"
 )
 
 type ProductService struct {
-	productRepo *postgres.ProductRepository
-	cacheRepo   *redis.CacheRepository
+	productRepo  ProductRepository
+	movementRepo StockMovementRepository
+	cacheRepo    cache.Cache
+	baseCurrency string
 }
 
-func NewProductService(productRepo *postgres.ProductRepository, cacheRepo *redis.CacheRepository) *ProductService {
+// NewProductService creates the service. Base prices of products are kept in
+// baseCurrency; other currencies go to the price lists.
+func NewProductService(
+	productRepo ProductRepository,
+	movementRepo StockMovementRepository,
+	cacheRepo cache.Cache,
+	baseCurrency string,
+) *ProductService {
 	return &ProductService{
-		productRepo: productRepo,
-		cacheRepo:   cacheRepo,
+		productRepo:  productRepo,
+		movementRepo: movementRepo,
+		cacheRepo:    cacheRepo,
+		baseCurrency: baseCurrency,
 	}
 }
 
+func (s *ProductService) BaseCurrency() string {
+	return s.baseCurrency
+}
+
 func (s *ProductService) Create(ctx context.Context, req *models.CreateProductRequest) (*models.Product, error) {
+	if reason := s.basePriceProblem(req.Price); reason != "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPrice, reason)
+	}
+
 	product := &models.Product{
 		Name:        req.Name,
 		Description: req.Description,
@@ -30,38 +51,91 @@ func (s *ProductService) Create(ctx context.Context, req *models.CreateProductRe
 		Stock:       req.Stock,
 	}
 
-	if err := s.productRepo.Create(product); err != nil {
+	tx, err := s.productRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.productRepo.CreateTx(tx, product); err != nil {
+		return nil, err
+	}
+	if product.Stock != 0 {
+		if err := s.movementRepo.CreateTx(tx, &models.StockMovement{
+			ProductID:      product.ID,
+			Reason:         models.StockReasonInitial,
+			Delta:          product.Stock,
+			ResultingStock: product.Stock,
+			Actor:          ActorFromContext(ctx),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
+	product.Available = product.Stock
 
-	s.cacheRepo.Delete(ctx, redis.ProductListKey(1, 100))
+	s.cacheRepo.Delete(ctx, cache.ProductKey(product.ID))
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
 
 	return product, nil
 }
 
 func (s *ProductService) GetByID(ctx context.Context, id int) (*models.Product, error) {
-	cacheKey := redis.ProductKey(id)
 	var product models.Product
 
-	err := s.cacheRepo.Get(ctx, cacheKey, &product)
-	if err == nil {
-		return &product, nil
-	}
-
-	productPtr, err := s.productRepo.GetByID(id)
+	found, err := s.cacheRepo.GetOrLoad(ctx, cache.ProductKey(id), &product, func(ctx context.Context) (interface{}, error) {
+		productPtr, err := s.withPrices(s.productRepo.GetByID(id))
+		if err != nil || productPtr == nil {
+			return nil, err
+		}
+		return productPtr, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if productPtr == nil {
+	if !found {
 		return nil, nil
 	}
 
-	s.cacheRepo.Set(ctx, cacheKey, productPtr)
+	return &product, nil
+}
 
-	return productPtr, nil
+// GetByIDWithDeleted also returns soft-deleted products. It bypasses the
+// cache.
+func (s *ProductService) GetByIDWithDeleted(ctx context.Context, id int) (*models.Product, error) {
+	return s.withPrices(s.productRepo.GetByIDWithDeleted(id))
 }
 
-func (s *ProductService) GetAll(ctx context.Context, page, limit int) ([]models.Product, error) {
+// withPrices loads the price list of a product fetched with err.
+func (s *ProductService) withPrices(product *models.Product, err error) (*models.Product, error) {
+	if err != nil || product == nil {
+		return product, err
+	}
+	product.Prices, err = s.productRepo.GetPrices(product.ID)
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// basePriceProblem explains why price cannot be the base price of a product,
+// or returns "" if it can.
+func (s *ProductService) basePriceProblem(price models.Money) string {
+	switch {
+	case price.Currency == "":
+		return "price is required"
+	case price.Currency != s.baseCurrency:
+		return fmt.Sprintf("price must be in %s; other currencies go to the price list", s.baseCurrency)
+	case price.Amount <= 0:
+		return "price must be greater than zero"
+	}
+	return ""
+}
+
+func (s *ProductService) GetAll(ctx context.Context, filter models.ProductFilter, page, limit int) ([]models.Product, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -70,32 +144,141 @@ func (s *ProductService) GetAll(ctx context.Context, page, limit int) ([]models.
 	}
 
 	offset := (page - 1) * limit
+	filter.Normalize()
 
-	cacheKey := redis.ProductListKey(page, limit)
+	gen, genErr := s.cacheRepo.Generation(ctx, cache.ProductsNamespace)
+	cacheKey := cache.ProductListKey(gen, page, limit, filter.CacheKey())
 	var products []models.Product
 
-	err := s.cacheRepo.Get(ctx, cacheKey, &products)
-	if err == nil {
-		return products, nil
+	if genErr == nil {
+		if err := s.cacheRepo.Get(ctx, cacheKey, &products); err == nil {
+			return products, nil
+		}
 	}
 
-	products, err = s.productRepo.GetAll(limit, offset)
+	products, err := s.productRepo.GetAll(filter, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 
-	s.cacheRepo.Set(ctx, cacheKey, products)
+	if genErr == nil {
+		s.cacheRepo.Set(ctx, cacheKey, products)
+	}
 
 	return products, nil
 }
 
-func (s *ProductService) Update(ctx context.Context, id int, req *models.UpdateProductRequest) (*models.Product, error) {
-	existing, err := s.productRepo.GetByID(id)
+// GetPage returns a keyset-paginated slice of products. An empty cursor
+// starts from the beginning of the list.
+func (s *ProductService) GetPage(ctx context.Context, filter models.ProductFilter, cursor string, limit int) (*models.CursorPage[models.Product], error) {
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	filter.Normalize()
+
+	var after *models.Cursor
+	if cursor != "" {
+		c, err := decodeProductCursor(filter, cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	gen, genErr := s.cacheRepo.Generation(ctx, cache.ProductsNamespace)
+	cacheKey := cache.ProductCursorKey(gen, cursor, limit, filter.CacheKey())
+	var page models.CursorPage[models.Product]
+
+	if genErr == nil {
+		if err := s.cacheRepo.Get(ctx, cacheKey, &page); err == nil {
+			return &page, nil
+		}
+	}
+
+	products, hasMore, err := s.productRepo.GetPage(filter, after, limit)
 	if err != nil {
 		return nil, err
 	}
-	if existing == nil {
-		return nil, sql.ErrNoRows
+
+	page = models.CursorPage[models.Product]{Data: products}
+	if len(products) > 0 {
+		backward := after != nil && after.Backward
+		first, last := products[0], products[len(products)-1]
+
+		if hasMore || backward {
+			page.NextCursor = productCursor(filter, last, false).Encode()
+		}
+		if (hasMore && backward) || (after != nil && !backward) {
+			page.PrevCursor = productCursor(filter, first, true).Encode()
+		}
+	}
+
+	if genErr == nil {
+		s.cacheRepo.Set(ctx, cacheKey, page)
+	}
+
+	return &page, nil
+}
+
+// decodeProductCursor decodes a cursor made by productCursor for the same
+// sort order. The sort value is checked here, since the repositories compare
+// it with a typed column.
+func decodeProductCursor(filter models.ProductFilter, cursor string) (*models.Cursor, error) {
+	c, err := models.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if c.Sort != filter.Sort || c.Order != filter.Order {
+		return nil, fmt.Errorf("%w: cursor does not match sort order", models.ErrInvalidCursor)
+	}
+
+	valid := true
+	switch c.Sort {
+	case "price":
+		_, err := strconv.ParseInt(c.Value, 10, 64)
+		valid = err == nil
+	case "created_at":
+		_, err := time.Parse(time.RFC3339Nano, c.Value)
+		valid = err == nil
+	case "id":
+		valid = c.Value == ""
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: malformed %s value", models.ErrInvalidCursor, c.Sort)
+	}
+	return c, nil
+}
+
+func productCursor(filter models.ProductFilter, p models.Product, backward bool) models.Cursor {
+	c := models.Cursor{Sort: filter.Sort, Order: filter.Order, ID: p.ID, Backward: backward}
+	switch filter.Sort {
+	case "price":
+		c.Value = strconv.FormatInt(p.Price.Amount, 10)
+	case "name":
+		c.Value = p.Name
+	case "created_at":
+		c.Value = p.CreatedAt.Format(time.RFC3339Nano)
+	}
+	return c
+}
+
+// Export streams every product matching filter to fn, bypassing the cache.
+// An error from fn or the end of ctx stops the export.
+func (s *ProductService) Export(ctx context.Context, filter models.ExportFilter, fn func(models.Product) error) error {
+	return s.productRepo.Export(ctx, filter, fn)
+}
+
+// Update replaces the product's fields. A non-zero version makes the write
+// fail with ErrPreconditionFailed if the product has changed since that
+// version was read.
+func (s *ProductService) Update(ctx context.Context, id int, req *models.UpdateProductRequest, version int) (*models.Product, error) {
+	switch {
+	case req.Name == "":
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidUpdate)
+	case s.basePriceProblem(req.Price) != "":
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUpdate, s.basePriceProblem(req.Price))
+	case req.Stock < 0:
+		return nil, fmt.Errorf("%w: stock cannot be negative", ErrInvalidUpdate)
 	}
 
 	product := &models.Product{
@@ -105,46 +288,260 @@ func (s *ProductService) Update(ctx context.Context, id int, req *models.UpdateP
 		Stock:       req.Stock,
 	}
 
-	if err := s.productRepo.Update(id, product); err != nil {
+	return s.update(ctx, id, product.Stock, func(tx repository.Tx) error {
+		return s.productRepo.UpdateTx(tx, id, product, version)
+	})
+}
+
+// Patch applies a merge patch to the product under the same version rule as
+// Update. Only the description may be cleared with null.
+func (s *ProductService) Patch(ctx context.Context, id int, patch *models.ProductPatch, version int) (*models.Product, error) {
+	switch {
+	case patch.Name.Set && patch.Name.Value == "":
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidUpdate)
+	case patch.Price.Set && patch.Price.Null:
+		return nil, fmt.Errorf("%w: price cannot be null", ErrInvalidUpdate)
+	case patch.Price.Set && s.basePriceProblem(patch.Price.Value) != "":
+		return nil, fmt.Errorf("%w: %s", ErrInvalidUpdate, s.basePriceProblem(patch.Price.Value))
+	case patch.Stock.Set && (patch.Stock.Null || patch.Stock.Value < 0):
+		return nil, fmt.Errorf("%w: stock cannot be null or negative", ErrInvalidUpdate)
+	}
+
+	stock := -1
+	if patch.Stock.Set {
+		stock = patch.Stock.Value
+	}
+
+	return s.update(ctx, id, stock, func(tx repository.Tx) error {
+		return s.productRepo.PatchTx(tx, id, patch, version)
+	})
+}
+
+// update runs write with the product row locked and records an adjustment
+// movement when newStock differs from the current stock. A negative newStock
+// means write leaves stock alone. Stock cannot be set below what is reserved;
+// reservations lock the same row, so the check cannot race with them.
+func (s *ProductService) update(ctx context.Context, id, newStock int, write func(tx repository.Tx) error) (*models.Product, error) {
+	tx, err := s.productRepo.BeginTx()
+	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	s.cacheRepo.Delete(ctx, redis.ProductKey(id), redis.ProductListKey(1, 100))
+	existing, err := s.productRepo.GetByIDForUpdate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrProductNotFound
+	}
+	if newStock >= 0 && newStock != existing.Stock && newStock < existing.Reserved {
+		return nil, reservedStockError(existing, newStock)
+	}
 
-	return s.productRepo.GetByID(id)
+	if err := write(tx); err != nil {
+		return nil, fromRepository(err, ErrProductNotFound)
+	}
+	if delta := newStock - existing.Stock; newStock >= 0 && delta != 0 {
+		if err := s.movementRepo.CreateTx(tx, &models.StockMovement{
+			ProductID:      id,
+			Reason:         models.StockReasonAdjustment,
+			Delta:          delta,
+			ResultingStock: newStock,
+			Actor:          ActorFromContext(ctx),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.cacheRepo.Delete(ctx, cache.ProductKey(id))
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+
+	return s.withPrices(s.productRepo.GetByID(id))
 }
 
-func (s *ProductService) UpdateStock(ctx context.Context, id, quantity int) error {
-	product, err := s.productRepo.GetByID(id)
+// reservedStockError reports an attempt to set the stock of product below its
+// reserved units.
+func reservedStockError(product *models.Product, newStock int) error {
+	return fmt.Errorf("%w: %d units of product %d are reserved, stock cannot be set to %d",
+		ErrInsufficientStock, product.Reserved, product.ID, newStock)
+}
+
+// SetPrice adds or replaces the price of the product in price.Currency, under
+// the same version rule as Update. The base price is changed through Update
+// and Patch instead.
+func (s *ProductService) SetPrice(ctx context.Context, id int, price models.Money, version int) (*models.Product, error) {
+	switch {
+	case price.Currency == s.baseCurrency:
+		return nil, fmt.Errorf("%w: the %s price is the base price; change it with PUT or PATCH", ErrInvalidPrice, s.baseCurrency)
+	case price.Amount <= 0:
+		return nil, fmt.Errorf("%w: price must be greater than zero", ErrInvalidPrice)
+	}
+
+	return s.update(ctx, id, -1, func(tx repository.Tx) error {
+		if err := s.productRepo.PatchTx(tx, id, &models.ProductPatch{}, version); err != nil {
+			return err
+		}
+		return s.productRepo.SetPriceTx(tx, id, price)
+	})
+}
+
+// DeletePrice removes the price of the product in currency from its price
+// list, under the same version rule as Update.
+func (s *ProductService) DeletePrice(ctx context.Context, id int, currency string, version int) (*models.Product, error) {
+	return s.update(ctx, id, -1, func(tx repository.Tx) error {
+		if err := s.productRepo.PatchTx(tx, id, &models.ProductPatch{}, version); err != nil {
+			return err
+		}
+		err := s.productRepo.DeletePriceTx(tx, id, currency)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: product %d has no price in %s", ErrPriceNotFound, id, currency)
+		}
+		return err
+	})
+}
+
+// UpdateStock records a sale of quantity units.
+func (s *ProductService) UpdateStock(ctx context.Context, id, quantity, version int) error {
+	return s.changeStock(ctx, id, quantity, version, models.StockReasonSale)
+}
+
+// Restock receives quantity units into the warehouse.
+func (s *ProductService) Restock(ctx context.Context, id, quantity, version int) error {
+	return s.changeStock(ctx, id, quantity, version, models.StockReasonRestock)
+}
+
+// changeStock checks version against the row it has locked, so the check
+// cannot race with another writer.
+func (s *ProductService) changeStock(ctx context.Context, id, quantity, version int, reason string) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	tx, err := s.productRepo.BeginTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	product, err := s.productRepo.GetByIDForUpdate(tx, id)
 	if err != nil {
 		return err
 	}
 	if product == nil {
-		return sql.ErrNoRows
+		return ErrProductNotFound
+	}
+	if version != 0 && product.Version != version {
+		return ErrPreconditionFailed
+	}
+
+	delta := quantity
+	var newStock int
+	if reason == models.StockReasonRestock {
+		newStock, err = s.productRepo.RestockTx(tx, id, quantity)
+	} else {
+		if product.Available < quantity {
+			return fmt.Errorf("%w: available %d, requested %d",
+				ErrInsufficientStock, product.Available, quantity)
+		}
+		delta = -quantity
+		newStock, err = s.productRepo.UpdateStockTx(tx, id, quantity)
 	}
-	if product.Stock < quantity {
-		return fmt.Errorf("insufficient stock: available %d, requested %d", product.Stock, quantity)
+	if err != nil {
+		return err
 	}
 
-	if err := s.productRepo.UpdateStock(id, quantity); err != nil {
+	if err := s.movementRepo.CreateTx(tx, &models.StockMovement{
+		ProductID:      id,
+		Reason:         reason,
+		Delta:          delta,
+		ResultingStock: newStock,
+		Actor:          ActorFromContext(ctx),
+	}); err != nil {
 		return err
 	}
 
-	s.cacheRepo.Delete(ctx, redis.ProductKey(id), redis.ProductListKey(1, 100))
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.cacheRepo.Delete(ctx, cache.ProductKey(id))
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
 
 	return nil
 }
 
-func (s *ProductService) Delete(ctx context.Context, id int) error {
-	if err := s.productRepo.Delete(id); err != nil {
-		return err
+func (s *ProductService) GetStockHistory(ctx context.Context, id, page, limit int) ([]models.StockMovement, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	product, err := s.productRepo.GetByID(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if product == nil {
+		return nil, 0, ErrProductNotFound
+	}
+
+	movements, err := s.movementRepo.GetByProductID(id, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := s.movementRepo.CountByProductID(id)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	s.cacheRepo.Delete(ctx, redis.ProductKey(id), redis.ProductListKey(1, 100))
+	return movements, total, nil
+}
+
+func (s *ProductService) Delete(ctx context.Context, id, version int) error {
+	if err := s.productRepo.Delete(id, version); err != nil {
+		return fromRepository(err, ErrProductNotFound)
+	}
+
+	s.cacheRepo.Delete(ctx, cache.ProductKey(id))
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
 
 	return nil
 }
 
-func (s *ProductService) Count(ctx context.Context) (int, error) {
-	return s.productRepo.Count()
+// Restore brings back a soft-deleted product.
+func (s *ProductService) Restore(ctx context.Context, id int) (*models.Product, error) {
+	if err := s.productRepo.Restore(id); err != nil {
+		return nil, fromRepository(err, ErrProductNotFound)
+	}
+
+	s.cacheRepo.Delete(ctx, cache.ProductKey(id))
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+
+	return s.withPrices(s.productRepo.GetByID(id))
+}
+
+// Purge hard-deletes products soft-deleted before the given time. It returns
+// how many were removed and how many were kept because orders or the stock
+// ledger reference them.
+func (s *ProductService) Purge(ctx context.Context, before time.Time) (purged, kept int64, err error) {
+	purged, kept, err = s.productRepo.Purge(before)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+
+	return purged, kept, nil
+}
+
+func (s *ProductService) Count(ctx context.Context, filter models.ProductFilter) (int, error) {
+	filter.Normalize()
+	return s.productRepo.Count(filter)
 }
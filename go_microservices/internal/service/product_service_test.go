@@ -0,0 +1,308 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+func TestProductServiceUpdateStock(t *testing.T) {
+	s := newServices(t)
+	ctx := service.WithActor(context.Background(), "test")
+	product := s.createProduct(t, 5)
+
+	if err := s.products.UpdateStock(ctx, product.ID, 3, product.Version); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.products.UpdateStock(ctx, product.ID, 3, 0); !errors.Is(err, service.ErrInsufficientStock) {
+		t.Fatalf("selling 3 of 2: got %v, want %v", err, service.ErrInsufficientStock)
+	}
+	if err := s.products.UpdateStock(ctx, product.ID, 1, product.Version); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Fatalf("selling with a stale version: got %v, want %v", err, service.ErrPreconditionFailed)
+	}
+	if err := s.products.UpdateStock(ctx, 42, 1, 0); !errors.Is(err, service.ErrProductNotFound) {
+		t.Fatalf("selling a missing product: got %v, want %v", err, service.ErrProductNotFound)
+	}
+
+	got, err := s.products.GetByID(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 2 || got.Version != product.Version+1 {
+		t.Fatalf("after one sale: got stock %d, version %d", got.Stock, got.Version)
+	}
+
+	movements, total, err := s.products.GetStockHistory(ctx, product.ID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || movements[0].Reason != models.StockReasonSale || movements[0].Delta != -3 ||
+		movements[0].ResultingStock != 2 || movements[0].Actor != "test" {
+		t.Fatalf("stock history: got %d movements, latest %+v", total, movements[0])
+	}
+}
+
+func TestProductServiceDelete(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+
+	if err := s.products.Delete(ctx, 42, 0); !errors.Is(err, service.ErrProductNotFound) {
+		t.Fatalf("Delete of a missing product: got %v, want %v", err, service.ErrProductNotFound)
+	}
+
+	product := s.createProduct(t, 1)
+	if err := s.products.Delete(ctx, product.ID, product.Version+1); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Fatalf("Delete with a stale version: got %v, want %v", err, service.ErrPreconditionFailed)
+	}
+	if err := s.products.Delete(ctx, product.ID, product.Version); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.products.GetByID(ctx, product.ID); err != nil || got != nil {
+		t.Fatalf("GetByID after delete: got %v, %v", got, err)
+	}
+}
+
+func TestProductServiceImportUpsert(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	widget := s.createProduct(t, 5)
+	s.createProduct(t, 1) // a second "Widget" makes the name ambiguous
+
+	gadget, err := s.products.Create(ctx, &models.CreateProductRequest{
+		Name:  "Gadget",
+		Price: models.Money{Amount: 100, Currency: "USD"},
+		Stock: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := func(line int, name string, stock int) models.ProductImportRow {
+		return models.ProductImportRow{Line: line, Fields: models.ProductPatch{
+			Name:  models.Optional[string]{Set: true, Value: name},
+			Stock: models.Optional[int]{Set: true, Value: stock},
+		}}
+	}
+	gizmo := row(3, "Gizmo", 3)
+	gizmo.Fields.Price = models.Optional[models.Money]{Set: true, Value: models.Money{Amount: 300, Currency: "USD"}}
+	report, err := s.products.Import(ctx, []models.ProductImportRow{
+		row(2, "Gadget", 7),
+		gizmo,
+		row(4, "Widget", 9),
+		row(5, "Doohickey", 1),
+	}, models.ProductImportOptions{Mode: models.ImportModeUpsert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Updated != 1 || report.Created != 1 || len(report.Rejected) != 2 {
+		t.Fatalf("import: got %+v", report)
+	}
+
+	got, err := s.products.GetByID(ctx, gadget.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 7 || got.Version != gadget.Version+1 || got.UpdatedAt.Before(gadget.UpdatedAt) {
+		t.Fatalf("imported product: got stock %d, version %d, updated_at %v", got.Stock, got.Version, got.UpdatedAt)
+	}
+	if got, err := s.products.GetByID(ctx, widget.ID); err != nil || got.Stock != 5 {
+		t.Fatalf("ambiguous product: got %v, %v", got, err)
+	}
+}
+
+func TestProductServiceKeepsReservedStock(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	product := s.createProduct(t, 5)
+	if _, err := s.reservations.Reserve(ctx, product.ID, &models.CreateReservationRequest{Quantity: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	update := &models.UpdateProductRequest{Name: "Widget", Price: product.Price, Stock: 2}
+	if _, err := s.products.Update(ctx, product.ID, update, 0); !errors.Is(err, service.ErrInsufficientStock) {
+		t.Fatalf("Update to 2 with 3 reserved: got %v, want %v", err, service.ErrInsufficientStock)
+	}
+	patch := &models.ProductPatch{Stock: models.Optional[int]{Set: true, Value: 2}}
+	if _, err := s.products.Patch(ctx, product.ID, patch, 0); !errors.Is(err, service.ErrInsufficientStock) {
+		t.Fatalf("Patch to 2 with 3 reserved: got %v, want %v", err, service.ErrInsufficientStock)
+	}
+
+	report, err := s.products.Import(ctx, []models.ProductImportRow{{Line: 2, Fields: models.ProductPatch{
+		Name:  models.Optional[string]{Set: true, Value: "Widget"},
+		Stock: models.Optional[int]{Set: true, Value: 2},
+	}}}, models.ProductImportOptions{Mode: models.ImportModeUpsert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Updated != 0 || len(report.Rejected) != 1 {
+		t.Fatalf("import of stock 2 with 3 reserved: got %+v", report)
+	}
+
+	update.Stock = 3
+	got, err := s.products.Update(ctx, product.ID, update, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 3 || got.Available != 0 {
+		t.Fatalf("Update to the reserved stock: got stock %d, available %d", got.Stock, got.Available)
+	}
+}
+
+func TestProductServicePurgeReportsKeptProducts(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	stocked := s.createProduct(t, 5)
+	empty := s.createProduct(t, 0)
+	live := s.createProduct(t, 0)
+
+	for _, p := range []*models.Product{stocked, empty} {
+		if err := s.products.Delete(ctx, p.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The opening balance of the stocked product is in the ledger, which
+	// cannot lose rows, so that product stays and is reported.
+	purged, kept, err := s.products.Purge(ctx, time.Now().Add(time.Second))
+	if err != nil || purged != 1 || kept != 1 {
+		t.Fatalf("Purge: got %d purged, %d kept, %v", purged, kept, err)
+	}
+	for _, p := range []*models.Product{stocked, empty, live} {
+		got, err := s.products.GetByIDWithDeleted(ctx, p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got == nil) != (p == empty) {
+			t.Fatalf("product %d after purge: got %+v", p.ID, got)
+		}
+	}
+
+	purged, kept, err = s.products.Purge(ctx, time.Now().Add(time.Second))
+	if err != nil || purged != 0 || kept != 1 {
+		t.Fatalf("second Purge: got %d purged, %d kept, %v", purged, kept, err)
+	}
+}
+
+// walkPages follows next cursors from the first page, then prev cursors back,
+// and returns the product ids of every page in the order visited.
+func walkPages(t *testing.T, s *services, filter models.ProductFilter, limit int) (forward, backward [][]int) {
+	t.Helper()
+	ctx := context.Background()
+
+	ids := func(page *models.CursorPage[models.Product]) []int {
+		var ids []int
+		for _, p := range page.Data {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	page, err := s.products.GetPage(ctx, filter, "", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.PrevCursor != "" {
+		t.Fatal("the first page has a prev cursor")
+	}
+	forward = append(forward, ids(page))
+	for page.NextCursor != "" {
+		if page, err = s.products.GetPage(ctx, filter, page.NextCursor, limit); err != nil {
+			t.Fatal(err)
+		}
+		forward = append(forward, ids(page))
+	}
+
+	backward = append(backward, ids(page))
+	for page.PrevCursor != "" {
+		if page, err = s.products.GetPage(ctx, filter, page.PrevCursor, limit); err != nil {
+			t.Fatal(err)
+		}
+		backward = append([][]int{ids(page)}, backward...)
+	}
+	return forward, backward
+}
+
+func TestProductServiceGetPageEdges(t *testing.T) {
+	s := newServices(t)
+	for i := 0; i < 5; i++ {
+		s.createProduct(t, 1)
+	}
+
+	forward, backward := walkPages(t, s, models.ProductFilter{}, 2)
+	want := [][]int{{1, 2}, {3, 4}, {5}}
+	if fmt.Sprint(forward) != fmt.Sprint(want) || fmt.Sprint(backward) != fmt.Sprint(want) {
+		t.Fatalf("pages: got %v forward and %v backward, want %v", forward, backward, want)
+	}
+
+	// A full last page has no next cursor, and stepping back from it gives
+	// the page before.
+	forward, backward = walkPages(t, s, models.ProductFilter{Order: "desc"}, 5)
+	if fmt.Sprint(forward) != "[[5 4 3 2 1]]" || fmt.Sprint(backward) != "[[5 4 3 2 1]]" {
+		t.Fatalf("one full page: got %v forward and %v backward", forward, backward)
+	}
+}
+
+func TestProductServiceGetPageIsStableAcrossTies(t *testing.T) {
+	s := newServices(t)
+	for i := 0; i < 7; i++ {
+		s.createProduct(t, 1)
+	}
+
+	// Every product has the same name and price, and products created within
+	// a microsecond share created_at, so the id has to break the ties.
+	// Products are created in id order, so every sort gives the id order.
+	for _, sort := range []string{"price", "name", "created_at"} {
+		for _, order := range []string{"asc", "desc"} {
+			filter := models.ProductFilter{Sort: sort, Order: order}
+			forward, backward := walkPages(t, s, filter, 3)
+
+			want := [][]int{{1, 2, 3}, {4, 5, 6}, {7}}
+			if order == "desc" {
+				want = [][]int{{7, 6, 5}, {4, 3, 2}, {1}}
+			}
+			if fmt.Sprint(forward) != fmt.Sprint(want) || fmt.Sprint(backward) != fmt.Sprint(want) {
+				t.Fatalf("%s %s: got %v forward and %v backward, want %v", sort, order, forward, backward, want)
+			}
+		}
+	}
+}
+
+func TestProductServiceGetPageRejectsTamperedCursors(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	s.createProduct(t, 1)
+	s.createProduct(t, 1)
+
+	byPrice := models.ProductFilter{Sort: "price", Order: "asc"}
+	page, err := s.products.GetPage(ctx, byPrice, "", 1)
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: got %+v, %v", page, err)
+	}
+
+	for name, tt := range map[string]struct {
+		filter models.ProductFilter
+		cursor string
+	}{
+		"malformed":        {byPrice, "!!"},
+		"other sort field": {models.ProductFilter{Sort: "name", Order: "asc"}, page.NextCursor},
+		"other order":      {models.ProductFilter{Sort: "price", Order: "desc"}, page.NextCursor},
+		"price not a number": {byPrice, models.Cursor{
+			Sort: "price", Order: "asc", Value: "1 OR 1=1", ID: 1,
+		}.Encode()},
+		"created_at not a time": {models.ProductFilter{Sort: "created_at", Order: "asc"}, models.Cursor{
+			Sort: "created_at", Order: "asc", Value: "yesterday", ID: 1,
+		}.Encode()},
+		"value on id sort": {models.ProductFilter{}, models.Cursor{
+			Sort: "id", Order: "asc", Value: "1", ID: 1,
+		}.Encode()},
+	} {
+		if got, err := s.products.GetPage(ctx, tt.filter, tt.cursor, 1); !errors.Is(err, models.ErrInvalidCursor) {
+			t.Errorf("%s: got %+v, %v, want %v", name, got, err, models.ErrInvalidCursor)
+		}
+	}
+}
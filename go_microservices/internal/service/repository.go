@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+// The interfaces below describe what the services need from storage. They are
+// implemented by the postgres package and, for tests and local runs, by the
+// memory package.
+
+type UserRepository interface {
+	Create(user *models.User) error
+	GetByID(id int) (*models.User, error)
+	GetByIDWithDeleted(id int) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	GetAll(limit, offset int, includeDeleted bool) ([]models.User, error)
+	GetPage(cursor *models.Cursor, limit int, includeDeleted bool) ([]models.User, bool, error)
+	Export(ctx context.Context, filter models.ExportFilter, fn func(models.User) error) error
+	Update(id int, user *models.User, version int) error
+	Patch(id int, patch *models.UserPatch, version int) error
+	SetRole(id int, role string, version int) error
+	SetPasswordHash(id int, oldHash, newHash string) error
+	Delete(id, version int) error
+	Restore(id int) error
+	Purge(before time.Time) (purged, kept int64, err error)
+	Count(includeDeleted bool) (int, error)
+}
+
+type ProductRepository interface {
+	BeginTx() (repository.Tx, error)
+	CreateTx(tx repository.Tx, product *models.Product) error
+	CreateManyTx(tx repository.Tx, products []*models.Product) error
+	GetByID(id int) (*models.Product, error)
+	GetByIDWithDeleted(id int) (*models.Product, error)
+	GetByIDForUpdate(tx repository.Tx, id int) (*models.Product, error)
+	GetByNamesForUpdate(tx repository.Tx, names []string) ([]models.Product, error)
+	GetAll(filter models.ProductFilter, limit, offset int) ([]models.Product, error)
+	GetPage(filter models.ProductFilter, cursor *models.Cursor, limit int) ([]models.Product, bool, error)
+	Export(ctx context.Context, filter models.ExportFilter, fn func(models.Product) error) error
+	UpdateTx(tx repository.Tx, id int, product *models.Product, version int) error
+	UpdateManyTx(tx repository.Tx, products []*models.Product) error
+	PatchTx(tx repository.Tx, id int, patch *models.ProductPatch, version int) error
+	UpdateStockTx(tx repository.Tx, id, quantity int) (int, error)
+	RestockTx(tx repository.Tx, id, quantity int) (int, error)
+	GetPrices(productID int) ([]models.Money, error)
+	SetPriceTx(tx repository.Tx, productID int, price models.Money) error
+	DeletePriceTx(tx repository.Tx, productID int, currency string) error
+	Delete(id, version int) error
+	Restore(id int) error
+	Purge(before time.Time) (purged, kept int64, err error)
+	Count(filter models.ProductFilter) (int, error)
+}
+
+// CategoryRepository stores the category tree. Writes that change its shape
+// run in a transaction that first calls LockTx, so concurrent moves cannot
+// create a cycle.
+type CategoryRepository interface {
+	BeginTx() (repository.Tx, error)
+	LockTx(tx repository.Tx) error
+	CreateTx(tx repository.Tx, category *models.Category) error
+	GetAll() ([]models.Category, error)
+	GetPathTx(tx repository.Tx, id int) ([]int, error)
+	CountTx(tx repository.Tx, ids []int) (int, error)
+	HasChildrenTx(tx repository.Tx, id int) (bool, error)
+	UpdateTx(tx repository.Tx, category *models.Category) error
+	DeleteTx(tx repository.Tx, id int) error
+	GetByProductID(productID int) ([]models.Category, error)
+	SetProductCategoriesTx(tx repository.Tx, productID int, categoryIDs []int) error
+}
+
+type OrderRepository interface {
+	BeginTx() (repository.Tx, error)
+	CreateTx(tx repository.Tx, order *models.Order) error
+	GetByID(id int) (*models.Order, error)
+	GetByUserID(userID, limit, offset int) ([]models.Order, error)
+	CountByUserID(userID int) (int, error)
+}
+
+type ReservationRepository interface {
+	BeginTx() (repository.Tx, error)
+	CreateTx(tx repository.Tx, reservation *models.Reservation) error
+	GetByID(id int) (*models.Reservation, error)
+	GetByIDForUpdate(tx repository.Tx, id int) (*models.Reservation, error)
+	UpdateStatusTx(tx repository.Tx, reservation *models.Reservation) error
+	ExpireDue(now time.Time) ([]int, error)
+}
+
+type StockMovementRepository interface {
+	CreateTx(tx repository.Tx, movement *models.StockMovement) error
+	CreateManyTx(tx repository.Tx, movements []*models.StockMovement) error
+	GetByProductID(productID, limit, offset int) ([]models.StockMovement, error)
+	CountByProductID(productID int) (int, error)
+}
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	GetByID(id int) (*models.APIKey, error)
+	GetByHash(keyHash string) (*models.APIKey, error)
+	GetAll() ([]models.APIKey, error)
+	Revoke(id int) error
+	TouchLastUsed(id int, at time.Time) error
+}
+
+// RefreshTokenStore keeps the refresh tokens that were issued and are still
+// usable, keyed by auth.HashSecret of the token. It is backed by Redis.
+type RefreshTokenStore interface {
+	Save(ctx context.Context, tokenHash string, userID int, ttl time.Duration) error
+	// Consume deletes a token and returns the user it was issued to, or 0 if
+	// the token is unknown, expired or already consumed. A token can be
+	// consumed only once, even by concurrent callers.
+	Consume(ctx context.Context, tokenHash string) (int, error)
+}
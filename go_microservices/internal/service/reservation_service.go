@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository"
+)
+
+type ReservationService struct {
+	reservationRepo ReservationRepository
+	productRepo     ProductRepository
+	movementRepo    StockMovementRepository
+	cacheRepo       cache.Cache
+	ttl             time.Duration
+}
+
+func NewReservationService(
+	reservationRepo ReservationRepository,
+	productRepo ProductRepository,
+	movementRepo StockMovementRepository,
+	cacheRepo cache.Cache,
+	ttl time.Duration,
+) *ReservationService {
+	return &ReservationService{
+		reservationRepo: reservationRepo,
+		productRepo:     productRepo,
+		movementRepo:    movementRepo,
+		cacheRepo:       cacheRepo,
+		ttl:             ttl,
+	}
+}
+
+// Reserve holds quantity units of a product without touching physical stock.
+// Held units are excluded from the product's available stock until the
+// reservation is committed, released or expires.
+func (s *ReservationService) Reserve(ctx context.Context, productID int, req *models.CreateReservationRequest) (*models.Reservation, error) {
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	ttl := s.ttl
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	tx, err := s.reservationRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	product, err := s.productRepo.GetByIDForUpdate(tx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	if product.Available < req.Quantity {
+		return nil, fmt.Errorf("%w: available %d, requested %d",
+			ErrInsufficientStock, product.Available, req.Quantity)
+	}
+
+	reservation := &models.Reservation{
+		ProductID: productID,
+		Quantity:  req.Quantity,
+		Status:    models.ReservationStatusActive,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.reservationRepo.CreateTx(tx, reservation); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidateProducts(ctx, productID)
+
+	return reservation, nil
+}
+
+func (s *ReservationService) GetByID(ctx context.Context, id int) (*models.Reservation, error) {
+	return s.reservationRepo.GetByID(id)
+}
+
+// Commit turns an active reservation into a permanent stock decrement. It
+// fails if the product has been deleted since, or its stock no longer covers
+// the reservation.
+func (s *ReservationService) Commit(ctx context.Context, id int) (*models.Reservation, error) {
+	tx, err := s.reservationRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reservation, err := s.lockActive(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checking the locked product tells apart the two reasons UpdateStockTx
+	// can fail for.
+	product, err := s.productRepo.GetByIDForUpdate(tx, reservation.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	if available := product.Available + reservation.Quantity; available < reservation.Quantity {
+		return nil, fmt.Errorf("%w: available %d, reserved %d",
+			ErrInsufficientStock, available, reservation.Quantity)
+	}
+
+	reservation.Status = models.ReservationStatusCommitted
+	if err := s.reservationRepo.UpdateStatusTx(tx, reservation); err != nil {
+		return nil, err
+	}
+	newStock, err := s.productRepo.UpdateStockTx(tx, reservation.ProductID, reservation.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.movementRepo.CreateTx(tx, &models.StockMovement{
+		ProductID:      reservation.ProductID,
+		Reason:         models.StockReasonReservation,
+		Delta:          -reservation.Quantity,
+		ResultingStock: newStock,
+		Actor:          ActorFromContext(ctx),
+		Reference:      fmt.Sprintf("reservation:%d", reservation.ID),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidateProducts(ctx, reservation.ProductID)
+
+	return reservation, nil
+}
+
+// Release gives the held units back to available stock.
+func (s *ReservationService) Release(ctx context.Context, id int) (*models.Reservation, error) {
+	tx, err := s.reservationRepo.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reservation, err := s.lockActive(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation.Status = models.ReservationStatusReleased
+	if err := s.reservationRepo.UpdateStatusTx(tx, reservation); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.invalidateProducts(ctx, reservation.ProductID)
+
+	return reservation, nil
+}
+
+// StartReaper expires overdue reservations every interval until ctx is done.
+func (s *ReservationService) StartReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := s.ExpireDue(ctx); err != nil {
+					log.Printf("Reservation reaper failed: %v", err)
+				} else if n > 0 {
+					log.Printf("Reservation reaper expired reservations for %d products", n)
+				}
+			}
+		}
+	}()
+}
+
+// ExpireDue marks overdue reservations as expired and returns the number of
+// products whose available stock changed.
+func (s *ReservationService) ExpireDue(ctx context.Context) (int, error) {
+	productIDs, err := s.reservationRepo.ExpireDue(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if len(productIDs) > 0 {
+		s.invalidateProducts(ctx, productIDs...)
+	}
+	return len(productIDs), nil
+}
+
+func (s *ReservationService) lockActive(tx repository.Tx, id int) (*models.Reservation, error) {
+	reservation, err := s.reservationRepo.GetByIDForUpdate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, ErrReservationNotFound
+	}
+	if reservation.Status != models.ReservationStatusActive {
+		return nil, fmt.Errorf("%w: %s", ErrReservationNotActive, reservation.Status)
+	}
+	if !reservation.ExpiresAt.After(time.Now()) {
+		return nil, ErrReservationExpired
+	}
+	return reservation, nil
+}
+
+func (s *ReservationService) invalidateProducts(ctx context.Context, productIDs ...int) {
+	keys := make([]string, 0, len(productIDs))
+	for _, id := range productIDs {
+		keys = append(keys, cache.ProductKey(id))
+	}
+	s.cacheRepo.Delete(ctx, keys...)
+	s.cacheRepo.BumpGeneration(ctx, cache.ProductsNamespace)
+}
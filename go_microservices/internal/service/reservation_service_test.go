@@ -0,0 +1,60 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+func TestReservationServiceCommit(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	product := s.createProduct(t, 5)
+
+	reservation, err := s.reservations.Reserve(ctx, product.ID, &models.CreateReservationRequest{Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := s.reservations.Commit(ctx, reservation.ID)
+	if err != nil || committed.Status != models.ReservationStatusCommitted {
+		t.Fatalf("Commit: got %+v, %v", committed, err)
+	}
+	got, err := s.products.GetByID(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stock != 3 || got.Reserved != 0 {
+		t.Fatalf("after commit: got stock %d, reserved %d", got.Stock, got.Reserved)
+	}
+	if _, err := s.reservations.Commit(ctx, reservation.ID); !errors.Is(err, service.ErrReservationNotActive) {
+		t.Fatalf("committing twice: got %v, want %v", err, service.ErrReservationNotActive)
+	}
+}
+
+func TestReservationServiceCommitOfDeletedProduct(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	product := s.createProduct(t, 5)
+
+	reservation, err := s.reservations.Reserve(ctx, product.ID, &models.CreateReservationRequest{Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.products.Delete(ctx, product.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.reservations.Commit(ctx, reservation.ID); !errors.Is(err, service.ErrProductNotFound) {
+		t.Fatalf("Commit for a deleted product: got %v, want %v", err, service.ErrProductNotFound)
+	}
+	got, err := s.reservations.GetByID(ctx, reservation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != models.ReservationStatusActive {
+		t.Fatalf("reservation after a failed commit: got status %q", got.Status)
+	}
+}
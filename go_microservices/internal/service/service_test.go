@@ -0,0 +1,66 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go_microservices/internal/cache"
+	"go_microservices/internal/models"
+	"go_microservices/internal/repository/memory"
+	"go_microservices/internal/service"
+)
+
+// services wires the services to one in-memory store, the way main wires
+// them to one database.
+type services struct {
+	store        *memory.Store
+	users        *service.UserService
+	products     *service.ProductService
+	orders       *service.OrderService
+	reservations *service.ReservationService
+}
+
+func newServices(t *testing.T) *services {
+	t.Helper()
+
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepository(store)
+	productRepo := memory.NewProductRepository(store)
+	movementRepo := memory.NewStockMovementRepository(store)
+	c := cache.NewMemoryCache(100, cache.Options{TTL: time.Minute, NegativeTTL: time.Minute})
+
+	return &services{
+		store:    store,
+		users:    service.NewUserService(userRepo, c),
+		products: service.NewProductService(productRepo, movementRepo, c, "USD"),
+		orders:   service.NewOrderService(memory.NewOrderRepository(store), productRepo, userRepo, movementRepo, c),
+		reservations: service.NewReservationService(
+			memory.NewReservationRepository(store), productRepo, movementRepo, c, time.Minute,
+		),
+	}
+}
+
+func (s *services) createUser(t *testing.T, email string) *models.User {
+	t.Helper()
+
+	user, err := s.users.Create(context.Background(), &models.CreateUserRequest{Name: "Test", Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func (s *services) createProduct(t *testing.T, stock int) *models.Product {
+	t.Helper()
+
+	product, err := s.products.Create(context.Background(), &models.CreateProductRequest{
+		Name:  "Widget",
+		Price: models.Money{Amount: 250, Currency: "USD"},
+		Stock: stock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return product
+}
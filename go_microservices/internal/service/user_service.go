@@ -2,72 +2,73 @@ package service
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"go_microservices/internal/cache"
 	"go_microservices/internal/models"
-	"go_microservices/internal/repository/postgres"
-	"go_microservices/internal/repository/redis"
+	"go_microservices/internal/repository"
 )
 
 type UserService struct {
-	userRepo  *postgres.UserRepository
-	cacheRepo *redis.CacheRepository
+	userRepo  UserRepository
+	cacheRepo cache.Cache
 }
 
-func NewUserService(userRepo *postgres.UserRepository, cacheRepo *redis.CacheRepository) *UserService {
+func NewUserService(userRepo UserRepository, cacheRepo cache.Cache) *UserService {
 	return &UserService{
 		userRepo:  userRepo,
 		cacheRepo: cacheRepo,
 	}
 }
 
+// Create adds a user. The unique index on email decides whether the email is
+// taken, so concurrent sign-ups with one email cannot both succeed.
 func (s *UserService) Create(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
-	existing, err := s.userRepo.GetByEmail(req.Email)
-	if err != nil {
-		return nil, err
-	}
-	if existing != nil {
-		return nil, fmt.Errorf("user with email %s already exists", req.Email)
-	}
-
 	user := &models.User{
 		Name:  req.Name,
 		Email: req.Email,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
-		return nil, err
+		return nil, userError(err, user.Email)
 	}
 
-	s.cacheRepo.Delete(ctx, redis.UserListKey(1, 100))
+	s.cacheRepo.Delete(ctx, cache.UserKey(user.ID))
+	s.cacheRepo.BumpGeneration(ctx, cache.UsersNamespace)
 
 	return user, nil
 }
 
 func (s *UserService) GetByID(ctx context.Context, id int) (*models.User, error) {
-	cacheKey := redis.UserKey(id)
 	var user models.User
 
-	err := s.cacheRepo.Get(ctx, cacheKey, &user)
-	if err == nil {
-		return &user, nil
-	}
-
-	userPtr, err := s.userRepo.GetByID(id)
+	found, err := s.cacheRepo.GetOrLoad(ctx, cache.UserKey(id), &user, func(ctx context.Context) (interface{}, error) {
+		userPtr, err := s.userRepo.GetByID(id)
+		if err != nil || userPtr == nil {
+			return nil, err
+		}
+		return userPtr, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if userPtr == nil {
+	if !found {
 		return nil, nil
 	}
 
-	s.cacheRepo.Set(ctx, cacheKey, userPtr)
+	return &user, nil
+}
 
-	return userPtr, nil
+// GetByIDWithDeleted also returns soft-deleted users. It bypasses the cache.
+func (s *UserService) GetByIDWithDeleted(ctx context.Context, id int) (*models.User, error) {
+	return s.userRepo.GetByIDWithDeleted(id)
 }
 
-func (s *UserService) GetAll(ctx context.Context, page, limit int) ([]models.User, error) {
+// GetAll lists users. Listings that include soft-deleted users bypass the
+// cache.
+func (s *UserService) GetAll(ctx context.Context, page, limit int, includeDeleted bool) ([]models.User, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -77,31 +78,99 @@ func (s *UserService) GetAll(ctx context.Context, page, limit int) ([]models.Use
 
 	offset := (page - 1) * limit
 
-	cacheKey := redis.UserListKey(page, limit)
+	if includeDeleted {
+		return s.userRepo.GetAll(limit, offset, true)
+	}
+
+	gen, genErr := s.cacheRepo.Generation(ctx, cache.UsersNamespace)
+	cacheKey := cache.UserListKey(gen, page, limit)
 	var users []models.User
 
-	err := s.cacheRepo.Get(ctx, cacheKey, &users)
-	if err == nil {
-		return users, nil
+	if genErr == nil {
+		if err := s.cacheRepo.Get(ctx, cacheKey, &users); err == nil {
+			return users, nil
+		}
 	}
 
-	users, err = s.userRepo.GetAll(limit, offset)
+	users, err := s.userRepo.GetAll(limit, offset, false)
 	if err != nil {
 		return nil, err
 	}
 
-	s.cacheRepo.Set(ctx, cacheKey, users)
+	if genErr == nil {
+		s.cacheRepo.Set(ctx, cacheKey, users)
+	}
 
 	return users, nil
 }
 
-func (s *UserService) Update(ctx context.Context, id int, req *models.UpdateUserRequest) (*models.User, error) {
-	existing, err := s.userRepo.GetByID(id)
+// GetPage returns a keyset-paginated slice of users. An empty cursor starts
+// from the beginning of the list.
+func (s *UserService) GetPage(ctx context.Context, cursor string, limit int, includeDeleted bool) (*models.CursorPage[models.User], error) {
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var after *models.Cursor
+	if cursor != "" {
+		c, err := models.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != "id" || c.Order != "asc" || c.Value != "" {
+			return nil, fmt.Errorf("%w: not a user cursor", models.ErrInvalidCursor)
+		}
+		after = c
+	}
+
+	gen, genErr := s.cacheRepo.Generation(ctx, cache.UsersNamespace)
+	useCache := genErr == nil && !includeDeleted
+	cacheKey := cache.UserCursorKey(gen, cursor, limit)
+	var page models.CursorPage[models.User]
+
+	if useCache {
+		if err := s.cacheRepo.Get(ctx, cacheKey, &page); err == nil {
+			return &page, nil
+		}
+	}
+
+	users, hasMore, err := s.userRepo.GetPage(after, limit, includeDeleted)
 	if err != nil {
 		return nil, err
 	}
-	if existing == nil {
-		return nil, sql.ErrNoRows
+
+	page = models.CursorPage[models.User]{Data: users}
+	if len(users) > 0 {
+		backward := after != nil && after.Backward
+		first, last := users[0], users[len(users)-1]
+
+		if hasMore || backward {
+			page.NextCursor = models.Cursor{Sort: "id", Order: "asc", ID: last.ID}.Encode()
+		}
+		if (hasMore && backward) || (after != nil && !backward) {
+			page.PrevCursor = models.Cursor{Sort: "id", Order: "asc", ID: first.ID, Backward: true}.Encode()
+		}
+	}
+
+	if useCache {
+		s.cacheRepo.Set(ctx, cacheKey, page)
+	}
+
+	return &page, nil
+}
+
+// Export streams every user matching filter to fn, bypassing the cache. An
+// error from fn or the end of ctx stops the export.
+func (s *UserService) Export(ctx context.Context, filter models.ExportFilter, fn func(models.User) error) error {
+	return s.userRepo.Export(ctx, filter, fn)
+}
+
+// Update replaces the user's fields. A non-zero version makes the write fail
+// with ErrPreconditionFailed if the user has changed since that version was
+// read.
+func (s *UserService) Update(ctx context.Context, id int, req *models.UpdateUserRequest, version int) (*models.User, error) {
+	if req.Name == "" || req.Email == "" {
+		return nil, fmt.Errorf("%w: name and email are required", ErrInvalidUpdate)
 	}
 
 	user := &models.User{
@@ -109,25 +178,93 @@ func (s *UserService) Update(ctx context.Context, id int, req *models.UpdateUser
 		Email: req.Email,
 	}
 
-	if err := s.userRepo.Update(id, user); err != nil {
-		return nil, err
+	if err := s.userRepo.Update(id, user, version); err != nil {
+		return nil, userError(err, user.Email)
+	}
+
+	return s.afterUpdate(ctx, id)
+}
+
+// Patch applies a merge patch to the user under the same version rule as
+// Update. Neither field may be cleared.
+func (s *UserService) Patch(ctx context.Context, id int, patch *models.UserPatch, version int) (*models.User, error) {
+	if patch.Name.Set && patch.Name.Value == "" {
+		return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidUpdate)
+	}
+	if patch.Email.Set && patch.Email.Value == "" {
+		return nil, fmt.Errorf("%w: email cannot be empty", ErrInvalidUpdate)
+	}
+
+	if err := s.userRepo.Patch(id, patch, version); err != nil {
+		return nil, userError(err, patch.Email.Value)
+	}
+
+	return s.afterUpdate(ctx, id)
+}
+
+// SetRole changes the role of the user under the same version rule as
+// Update. Access tokens issued before carry the old role until they expire.
+func (s *UserService) SetRole(ctx context.Context, id int, role string, version int) (*models.User, error) {
+	if !models.ValidRole(role) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+
+	if err := s.userRepo.SetRole(id, role, version); err != nil {
+		return nil, fromRepository(err, ErrUserNotFound)
 	}
 
-	s.cacheRepo.Delete(ctx, redis.UserKey(id), redis.UserListKey(1, 100))
+	return s.afterUpdate(ctx, id)
+}
+
+func (s *UserService) afterUpdate(ctx context.Context, id int) (*models.User, error) {
+	s.cacheRepo.Delete(ctx, cache.UserKey(id))
+	s.cacheRepo.BumpGeneration(ctx, cache.UsersNamespace)
 
 	return s.userRepo.GetByID(id)
 }
 
-func (s *UserService) Delete(ctx context.Context, id int) error {
-	if err := s.userRepo.Delete(id); err != nil {
-		return err
+func (s *UserService) Delete(ctx context.Context, id, version int) error {
+	if err := s.userRepo.Delete(id, version); err != nil {
+		return fromRepository(err, ErrUserNotFound)
 	}
 
-	s.cacheRepo.Delete(ctx, redis.UserKey(id), redis.UserListKey(1, 100))
+	s.cacheRepo.Delete(ctx, cache.UserKey(id))
+	s.cacheRepo.BumpGeneration(ctx, cache.UsersNamespace)
 
 	return nil
 }
 
-func (s *UserService) Count(ctx context.Context) (int, error) {
-	return s.userRepo.Count()
+// Restore brings back a soft-deleted user.
+func (s *UserService) Restore(ctx context.Context, id int) (*models.User, error) {
+	if err := s.userRepo.Restore(id); err != nil {
+		return nil, fromRepository(err, ErrUserNotFound)
+	}
+
+	return s.afterUpdate(ctx, id)
+}
+
+// Purge hard-deletes users soft-deleted before the given time. It returns
+// how many were removed and how many were kept because they have orders.
+func (s *UserService) Purge(ctx context.Context, before time.Time) (purged, kept int64, err error) {
+	purged, kept, err = s.userRepo.Purge(before)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.cacheRepo.BumpGeneration(ctx, cache.UsersNamespace)
+
+	return purged, kept, nil
+}
+
+// userError is fromRepository for user writes; the only unique column of a
+// user is the email.
+func userError(err error, email string) error {
+	if errors.Is(err, repository.ErrDuplicate) {
+		return fmt.Errorf("%w: %s", ErrEmailTaken, email)
+	}
+	return fromRepository(err, ErrUserNotFound)
+}
+
+func (s *UserService) Count(ctx context.Context, includeDeleted bool) (int, error) {
+	return s.userRepo.Count(includeDeleted)
 }
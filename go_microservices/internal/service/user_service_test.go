@@ -0,0 +1,161 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go_microservices/internal/models"
+	"go_microservices/internal/service"
+)
+
+func TestUserServiceCreateRejectsTakenEmail(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	s.createUser(t, "ann@example.com")
+
+	_, err := s.users.Create(ctx, &models.CreateUserRequest{Name: "Other Ann", Email: "ann@example.com"})
+	if !errors.Is(err, service.ErrEmailTaken) {
+		t.Fatalf("Create with a taken email: got %v, want %v", err, service.ErrEmailTaken)
+	}
+
+	bob := s.createUser(t, "bob@example.com")
+	_, err = s.users.Update(ctx, bob.ID, &models.UpdateUserRequest{Name: "Bob", Email: "ann@example.com"}, 0)
+	if !errors.Is(err, service.ErrEmailTaken) {
+		t.Fatalf("Update to a taken email: got %v, want %v", err, service.ErrEmailTaken)
+	}
+}
+
+func TestUserServiceUpdateChecksVersion(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	user := s.createUser(t, "ann@example.com")
+
+	updated, err := s.users.Update(ctx, user.ID, &models.UpdateUserRequest{Name: "Anna", Email: "ann@example.com"}, user.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Version != user.Version+1 || updated.UpdatedAt.Before(user.UpdatedAt) {
+		t.Fatalf("updated user: version %d -> %d, updated_at %v -> %v",
+			user.Version, updated.Version, user.UpdatedAt, updated.UpdatedAt)
+	}
+
+	_, err = s.users.Update(ctx, user.ID, &models.UpdateUserRequest{Name: "Ann", Email: "ann@example.com"}, user.Version)
+	if !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Fatalf("Update with a stale version: got %v, want %v", err, service.ErrPreconditionFailed)
+	}
+}
+
+func TestUserServiceDelete(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+
+	if err := s.users.Delete(ctx, 42, 0); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("Delete of a missing user: got %v, want %v", err, service.ErrUserNotFound)
+	}
+
+	user := s.createUser(t, "ann@example.com")
+	// Fill the cache so the test also covers its invalidation.
+	if got, err := s.users.GetByID(ctx, user.ID); err != nil || got == nil {
+		t.Fatalf("GetByID: got %v, %v", got, err)
+	}
+	if err := s.users.Delete(ctx, user.ID, user.Version); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.users.GetByID(ctx, user.ID); err != nil || got != nil {
+		t.Fatalf("GetByID after delete: got %v, %v", got, err)
+	}
+	if err := s.users.Delete(ctx, user.ID, 0); !errors.Is(err, service.ErrUserNotFound) {
+		t.Fatalf("deleting twice: got %v, want %v", err, service.ErrUserNotFound)
+	}
+
+	restored, err := s.users.Restore(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || restored.Version != user.Version+2 {
+		t.Fatalf("restored user: deleted_at %v, version %d", restored.DeletedAt, restored.Version)
+	}
+}
+
+func TestUserServiceExportStopsWithContext(t *testing.T) {
+	s := newServices(t)
+	s.createUser(t, "ann@example.com")
+	s.createUser(t, "bob@example.com")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var exported []string
+	err := s.users.Export(ctx, models.ExportFilter{}, func(u models.User) error {
+		exported = append(exported, u.Email)
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || len(exported) != 1 {
+		t.Fatalf("Export cancelled after one user: got %v, %v", exported, err)
+	}
+}
+
+func TestUserServiceGetPage(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		s.createUser(t, email)
+	}
+
+	first, err := s.users.GetPage(ctx, "", 2, false)
+	if err != nil || len(first.Data) != 2 || first.NextCursor == "" || first.PrevCursor != "" {
+		t.Fatalf("first page: got %+v, %v", first, err)
+	}
+	last, err := s.users.GetPage(ctx, first.NextCursor, 2, false)
+	if err != nil || len(last.Data) != 1 || last.Data[0].ID != 3 || last.NextCursor != "" || last.PrevCursor == "" {
+		t.Fatalf("last page: got %+v, %v", last, err)
+	}
+	back, err := s.users.GetPage(ctx, last.PrevCursor, 2, false)
+	if err != nil || len(back.Data) != 2 || back.Data[0].ID != 1 || back.PrevCursor != "" || back.NextCursor == "" {
+		t.Fatalf("back to the first page: got %+v, %v", back, err)
+	}
+
+	for _, cursor := range []string{
+		"!!",
+		models.Cursor{Sort: "price", Order: "asc", Value: "100", ID: 1}.Encode(),
+		models.Cursor{Sort: "id", Order: "desc", ID: 1}.Encode(),
+	} {
+		if page, err := s.users.GetPage(ctx, cursor, 2, false); !errors.Is(err, models.ErrInvalidCursor) {
+			t.Errorf("GetPage(%q): got %+v, %v, want %v", cursor, page, err, models.ErrInvalidCursor)
+		}
+	}
+}
+
+func TestUserServiceCacheFollowsWrites(t *testing.T) {
+	s := newServices(t)
+	ctx := context.Background()
+
+	// The miss is cached as a tombstone, which creating the user removes.
+	if got, err := s.users.GetByID(ctx, 1); err != nil || got != nil {
+		t.Fatalf("GetByID of a missing user: got %v, %v", got, err)
+	}
+	ann := s.createUser(t, "ann@example.com")
+	if got, err := s.users.GetByID(ctx, ann.ID); err != nil || got == nil || got.Email != ann.Email {
+		t.Fatalf("GetByID after create: got %v, %v", got, err)
+	}
+
+	// Cached pages go stale with the generation every write bumps.
+	if got, err := s.users.GetAll(ctx, 1, 10, false); err != nil || len(got) != 1 {
+		t.Fatalf("GetAll: got %d users, %v", len(got), err)
+	}
+	s.createUser(t, "bob@example.com")
+	if got, err := s.users.GetAll(ctx, 1, 10, false); err != nil || len(got) != 2 {
+		t.Fatalf("GetAll after create: got %d users, %v", len(got), err)
+	}
+
+	if _, err := s.users.Update(ctx, ann.ID, &models.UpdateUserRequest{Name: "Anna", Email: ann.Email}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.users.GetByID(ctx, ann.ID); err != nil || got == nil || got.Name != "Anna" {
+		t.Fatalf("GetByID after update: got %v, %v", got, err)
+	}
+	users, err := s.users.GetAll(ctx, 1, 10, false)
+	if err != nil || len(users) != 2 || users[0].Name != "Anna" {
+		t.Fatalf("GetAll after update: got %v, %v", users, err)
+	}
+}
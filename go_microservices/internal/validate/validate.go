@@ -0,0 +1,223 @@
+// Package validate checks request structs against their gin-style binding
+// tags, e.g. `binding:"required,email,max=100"`.
+//
+// Supported rules:
+//
+//	required          the value is not empty (zero, "" or no items)
+//	omitempty         skip the remaining rules when the value is empty
+//	email             a bare address such as user@example.com
+//	gt, gte, lt, lte  compare numbers by value, strings by length in
+//	                  characters and slices by number of items
+//	min, max          aliases of gte and lte
+//
+// Nested structs and slices of structs are validated too. Fields are named by
+// their JSON keys, e.g. items[0].quantity.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes one invalid field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a struct.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Optional is implemented by wrappers such as models.Optional. The rules of a
+// field apply to the wrapped value; an absent value counts as empty.
+type Optional interface {
+	ValidationValue() (value interface{}, present bool)
+}
+
+var (
+	optionalType = reflect.TypeOf((*Optional)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// Struct validates v, a struct or a pointer to one, and returns Errors if any
+// field breaks its rules. A malformed tag is a programming error and panics.
+func Struct(v interface{}) error {
+	var errs Errors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, errs *Errors) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := fieldName(field)
+			if name == "" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			validateField(v.Field(i), field.Tag.Get("binding"), name, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateField(v reflect.Value, tag, name string, errs *Errors) {
+	present := true
+	if v.Type().Implements(optionalType) {
+		var value interface{}
+		value, present = v.Interface().(Optional).ValidationValue()
+		v = reflect.ValueOf(value)
+	}
+	empty := !present || isEmpty(v)
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if rule == "omitempty" {
+			if empty {
+				return
+			}
+			continue
+		}
+		if rule == "required" {
+			if empty {
+				*errs = append(*errs, FieldError{Field: name, Rule: rule, Message: "is required"})
+				return
+			}
+			continue
+		}
+		if !present {
+			continue
+		}
+		if message := check(v, rule); message != "" {
+			ruleName, _, _ := strings.Cut(rule, "=")
+			*errs = append(*errs, FieldError{Field: name, Rule: ruleName, Message: message})
+			return
+		}
+	}
+
+	if present {
+		validateValue(v, name, errs)
+	}
+}
+
+// check applies one rule and returns why the value breaks it, or "".
+func check(v reflect.Value, rule string) string {
+	name, param, _ := strings.Cut(rule, "=")
+
+	if name == "email" {
+		if v.Kind() != reflect.String {
+			panic(fmt.Sprintf("validate: email rule on %s", v.Type()))
+		}
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return "must be a valid email address"
+		}
+		return ""
+	}
+
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: malformed rule %q", rule))
+	}
+
+	var (
+		n    float64
+		unit string
+	)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	default:
+		panic(fmt.Sprintf("validate: %s rule on %s", name, v.Type()))
+	}
+
+	var ok bool
+	var message string
+	switch name {
+	case "gt":
+		ok, message = n > limit, "must be greater than "+param
+	case "gte", "min":
+		ok, message = n >= limit, "must be at least "+param
+	case "lt":
+		ok, message = n < limit, "must be less than "+param
+	case "lte", "max":
+		ok, message = n <= limit, "must be at most "+param
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	if ok {
+		return ""
+	}
+	return message + unit
+}
+
+func isEmpty(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// fieldName returns the JSON key of a field, or "" if it is not encoded.
+func fieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return field.Name
+}
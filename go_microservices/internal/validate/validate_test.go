@@ -0,0 +1,248 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// optional stands in for models.Optional.
+type optional[T any] struct {
+	value T
+	set   bool
+}
+
+func (o optional[T]) ValidationValue() (interface{}, bool) {
+	return o.value, o.set
+}
+
+func TestStructRules(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want Errors
+	}{
+		{
+			name: "required",
+			v: &struct {
+				Name  string   `json:"name" binding:"required"`
+				Count int      `json:"count" binding:"required"`
+				Tags  []string `json:"tags" binding:"required"`
+			}{Tags: []string{}},
+			want: Errors{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "count", Rule: "required", Message: "is required"},
+				{Field: "tags", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "required stops at the first failure",
+			v: struct {
+				Name string `json:"name" binding:"required,max=3"`
+			}{},
+			want: Errors{{Field: "name", Rule: "required", Message: "is required"}},
+		},
+		{
+			name: "omitempty skips the rules of an empty value",
+			v: struct {
+				Name  string `json:"name" binding:"omitempty,min=3,email"`
+				Count int    `json:"count" binding:"omitempty,gt=10"`
+			}{},
+		},
+		{
+			name: "omitempty keeps the rules of a value",
+			v: struct {
+				Count int `json:"count" binding:"omitempty,gt=10"`
+			}{Count: 5},
+			want: Errors{{Field: "count", Rule: "gt", Message: "must be greater than 10"}},
+		},
+		{
+			name: "numbers compare by value",
+			v: struct {
+				A int     `json:"a" binding:"gt=0"`
+				B int64   `json:"b" binding:"gte=1"`
+				C uint8   `json:"c" binding:"lt=10"`
+				D float64 `json:"d" binding:"lte=1.5"`
+				E int     `json:"e" binding:"min=2,max=4"`
+			}{A: 0, B: 0, C: 10, D: 1.6, E: 3},
+			want: Errors{
+				{Field: "a", Rule: "gt", Message: "must be greater than 0"},
+				{Field: "b", Rule: "gte", Message: "must be at least 1"},
+				{Field: "c", Rule: "lt", Message: "must be less than 10"},
+				{Field: "d", Rule: "lte", Message: "must be at most 1.5"},
+			},
+		},
+		{
+			name: "strings compare by characters",
+			v: struct {
+				Short string `json:"short" binding:"max=3"`
+				Long  string `json:"long" binding:"min=3"`
+			}{Short: "ééé", Long: "ab"},
+			want: Errors{{Field: "long", Rule: "min", Message: "must be at least 3 characters"}},
+		},
+		{
+			name: "slices compare by items",
+			v: struct {
+				IDs []int `json:"ids" binding:"max=2"`
+			}{IDs: []int{1, 2, 3}},
+			want: Errors{{Field: "ids", Rule: "max", Message: "must be at most 2 items"}},
+		},
+		{
+			name: "email",
+			v: struct {
+				A string `json:"a" binding:"email"`
+				B string `json:"b" binding:"email"`
+				C string `json:"c" binding:"email"`
+			}{A: "ann@example.com", B: "Ann <ann@example.com>", C: "ann"},
+			want: Errors{
+				{Field: "b", Rule: "email", Message: "must be a valid email address"},
+				{Field: "c", Rule: "email", Message: "must be a valid email address"},
+			},
+		},
+		{
+			name: "fields are named by their JSON keys",
+			v: struct {
+				Named   int `json:"named,omitempty" binding:"gt=0"`
+				Unnamed int `binding:"gt=0"`
+				Skipped int `json:"-" binding:"gt=0"`
+				hidden  int `binding:"gt=0"`
+			}{},
+			want: Errors{
+				{Field: "named", Rule: "gt", Message: "must be greater than 0"},
+				{Field: "Unnamed", Rule: "gt", Message: "must be greater than 0"},
+			},
+		},
+		{
+			name: "optional values",
+			v: struct {
+				Absent  optional[string] `json:"absent" binding:"omitempty,min=3"`
+				Short   optional[string] `json:"short" binding:"omitempty,min=3"`
+				Missing optional[int]    `json:"missing" binding:"required"`
+				Limited optional[int]    `json:"limited" binding:"gte=0"`
+			}{
+				Short:   optional[string]{value: "ab", set: true},
+				Limited: optional[int]{},
+			},
+			want: Errors{
+				{Field: "short", Rule: "min", Message: "must be at least 3 characters"},
+				{Field: "missing", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "time values are not descended into",
+			v: struct {
+				At time.Time `json:"at" binding:"required"`
+			}{At: time.Now()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want no error", err)
+				}
+				return
+			}
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+type item struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"gt=0"`
+}
+
+type address struct {
+	City string `json:"city" binding:"required"`
+}
+
+func TestStructNested(t *testing.T) {
+	v := &struct {
+		Items    []item   `json:"items" binding:"required,max=3"`
+		Pointers []*item  `json:"pointers"`
+		Address  address  `json:"address"`
+		Billing  *address `json:"billing"`
+		Shipping *address `json:"shipping"`
+	}{
+		Items:    []item{{ProductID: 1, Quantity: 1}, {Quantity: -1}},
+		Pointers: []*item{nil, {ProductID: 1}},
+		Billing:  &address{},
+	}
+
+	want := Errors{
+		{Field: "items[1].product_id", Rule: "required", Message: "is required"},
+		{Field: "items[1].quantity", Rule: "gt", Message: "must be greater than 0"},
+		{Field: "pointers[1].quantity", Rule: "gt", Message: "must be greater than 0"},
+		{Field: "address.city", Rule: "required", Message: "is required"},
+		{Field: "billing.city", Rule: "required", Message: "is required"},
+	}
+	var got Errors
+	if err := Struct(v); !errors.As(err, &got) || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", err, want)
+	}
+
+	// A slice that breaks its own rule is not checked item by item.
+	v.Items = []item{{}, {}, {}, {}}
+	v.Pointers, v.Address, v.Billing = nil, address{City: "Oslo"}, nil
+	want = Errors{{Field: "items", Rule: "max", Message: "must be at most 3 items"}}
+	if err := Struct(v); !errors.As(err, &got) || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", err, want)
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+	err := Errors{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "age", Rule: "gte", Message: "must be at least 0"},
+	}
+	if got, want := err.Error(), "validation failed: name is required; age must be at least 0"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestStructPanicsOnMalformedTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     interface{}
+		panic string
+	}{
+		{"unknown rule", struct {
+			N int `binding:"between=3"`
+		}{N: 1}, `unknown rule "between=3"`},
+		{"unknown rule without a parameter", struct {
+			N int `binding:"positive"`
+		}{N: 1}, `malformed rule "positive"`},
+		{"parameter that is not a number", struct {
+			N int `binding:"max=ten"`
+		}{N: 1}, `malformed rule "max=ten"`},
+		{"email on a number", struct {
+			N int `binding:"email"`
+		}{N: 1}, "email rule on int"},
+		{"comparison on a bool", struct {
+			B bool `binding:"gt=0"`
+		}{B: true}, "gt rule on bool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if s, _ := r.(string); !strings.Contains(s, tt.panic) {
+					t.Fatalf("got panic %v, want one containing %q", r, tt.panic)
+				}
+			}()
+			Struct(tt.v)
+		})
+	}
+}
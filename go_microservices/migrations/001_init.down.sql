@@ -0,0 +1,10 @@
+-- Dropping triggers
+DROP TRIGGER IF EXISTS update_products_updated_at ON products;
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+
+-- Dropping function for updating updated_at
+DROP FUNCTION IF EXISTS update_updated_at_column();
+
+-- Dropping tables
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS users;
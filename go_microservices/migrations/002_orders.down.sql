@@ -0,0 +1,3 @@
+-- Dropping orders tables
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,2 @@
+-- Dropping reservations table
+DROP TABLE IF EXISTS reservations;
@@ -0,0 +1,23 @@
+-- Creating reservations table
+CREATE TABLE IF NOT EXISTS reservations (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Creating indexes
+CREATE INDEX IF NOT EXISTS idx_reservations_active_product
+    ON reservations(product_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_reservations_active_expires_at
+    ON reservations(expires_at) WHERE status = 'active';
+
+-- Creating triggers
+DROP TRIGGER IF EXISTS update_reservations_updated_at ON reservations;
+CREATE TRIGGER update_reservations_updated_at
+    BEFORE UPDATE ON reservations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,5 @@
+-- Dropping stock_movements table
+DROP TABLE IF EXISTS stock_movements;
+
+-- Dropping function for rejecting ledger rewrites
+DROP FUNCTION IF EXISTS reject_stock_movement_update();
@@ -0,0 +1,36 @@
+-- Creating stock_movements table (append-only ledger of stock changes)
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    reason VARCHAR(30) NOT NULL,
+    delta INTEGER NOT NULL,
+    resulting_stock INTEGER NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    reference VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Creating indexes
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id
+    ON stock_movements(product_id, id DESC);
+
+-- Creating function for rejecting ledger rewrites
+CREATE OR REPLACE FUNCTION reject_stock_movement_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ language 'plpgsql';
+
+-- Creating triggers
+DROP TRIGGER IF EXISTS stock_movements_append_only ON stock_movements;
+CREATE TRIGGER stock_movements_append_only
+    BEFORE UPDATE ON stock_movements
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_stock_movement_update();
+
+-- Recording opening balances for existing products
+INSERT INTO stock_movements (product_id, reason, delta, resulting_stock, actor)
+SELECT p.id, 'initial', p.stock, p.stock, 'migration'
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id);
@@ -0,0 +1,6 @@
+-- Dropping indexes
+DROP INDEX IF EXISTS idx_products_created_at;
+DROP INDEX IF EXISTS idx_products_search_vector;
+
+-- Dropping full-text search vector
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,3 @@
+-- Dropping row versions
+ALTER TABLE products DROP COLUMN IF EXISTS version;
+ALTER TABLE users DROP COLUMN IF EXISTS version;
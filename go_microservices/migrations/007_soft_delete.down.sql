@@ -0,0 +1,7 @@
+-- Dropping indexes
+DROP INDEX IF EXISTS idx_products_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+
+-- Dropping soft-delete markers (soft-deleted rows become visible again)
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,14 @@
+-- Dropping product_prices table
+DROP TABLE IF EXISTS product_prices;
+
+-- Restoring decimal prices (every amount is read as having two minor digits)
+ALTER TABLE order_items RENAME COLUMN unit_price_minor TO unit_price;
+ALTER TABLE order_items ALTER COLUMN unit_price TYPE DECIMAL(10,2) USING unit_price / 100.0;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE orders RENAME COLUMN total_minor TO total;
+ALTER TABLE orders ALTER COLUMN total TYPE DECIMAL(12,2) USING total / 100.0;
+
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
+ALTER TABLE products RENAME COLUMN price_minor TO price;
+ALTER TABLE products ALTER COLUMN price TYPE DECIMAL(10,2) USING price / 100.0;
@@ -0,0 +1,21 @@
+-- Storing prices as integer minor units with an ISO 4217 currency (existing
+-- amounts are in USD)
+ALTER TABLE products ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100);
+ALTER TABLE products RENAME COLUMN price TO price_minor;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE orders ALTER COLUMN total TYPE BIGINT USING ROUND(total * 100);
+ALTER TABLE orders RENAME COLUMN total TO total_minor;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE order_items ALTER COLUMN unit_price TYPE BIGINT USING ROUND(unit_price * 100);
+ALTER TABLE order_items RENAME COLUMN unit_price TO unit_price_minor;
+
+-- Creating product_prices table (prices in currencies other than the base one)
+CREATE TABLE IF NOT EXISTS product_prices (
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL,
+    amount_minor BIGINT NOT NULL CHECK (amount_minor > 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (product_id, currency)
+);
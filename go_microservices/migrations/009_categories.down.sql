@@ -0,0 +1,6 @@
+-- Dropping triggers
+DROP TRIGGER IF EXISTS update_categories_updated_at ON categories;
+
+-- Dropping tables
+DROP TABLE IF EXISTS product_categories;
+DROP TABLE IF EXISTS categories;
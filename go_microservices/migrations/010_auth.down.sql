@@ -0,0 +1,2 @@
+-- Dropping password hashes
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
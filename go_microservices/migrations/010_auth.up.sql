@@ -0,0 +1,3 @@
+-- Adding password hashes to users. Users created before authentication or
+-- through POST /users have an empty hash and cannot log in.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT '';
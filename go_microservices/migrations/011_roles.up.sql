@@ -0,0 +1,7 @@
+-- Adding roles to users. Existing users become customers; the first admin is
+-- granted with the "role" subcommand.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer';
+
+-- Adding the allowed roles
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'staff', 'customer'));
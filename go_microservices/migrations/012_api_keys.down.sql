@@ -0,0 +1,2 @@
+-- Dropping api_keys table
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,9 @@
+-- Dropping triggers for rejecting ledger deletes
+DROP TRIGGER IF EXISTS stock_movements_no_truncate ON stock_movements;
+DROP TRIGGER IF EXISTS stock_movements_no_delete ON stock_movements;
+
+-- Deleting stock movements with their product again
+ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_product_id_fkey;
+ALTER TABLE stock_movements
+    ADD CONSTRAINT stock_movements_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE;
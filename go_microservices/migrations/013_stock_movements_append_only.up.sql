@@ -0,0 +1,18 @@
+-- Keeping stock movements when their product is deleted
+ALTER TABLE stock_movements DROP CONSTRAINT IF EXISTS stock_movements_product_id_fkey;
+ALTER TABLE stock_movements
+    ADD CONSTRAINT stock_movements_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT;
+
+-- Creating triggers for rejecting ledger deletes
+DROP TRIGGER IF EXISTS stock_movements_no_delete ON stock_movements;
+CREATE TRIGGER stock_movements_no_delete
+    BEFORE DELETE ON stock_movements
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_stock_movement_update();
+
+DROP TRIGGER IF EXISTS stock_movements_no_truncate ON stock_movements;
+CREATE TRIGGER stock_movements_no_truncate
+    BEFORE TRUNCATE ON stock_movements
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION reject_stock_movement_update();
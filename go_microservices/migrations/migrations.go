@@ -0,0 +1,8 @@
+// Package migrations embeds the numbered schema migrations so the binary can
+// apply them without the SQL files on disk.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS